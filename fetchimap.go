@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/huhndev/godmarc/ingest"
+	"github.com/huhndev/godmarc/storage"
+)
+
+// runFetchIMAP implements the fetch-imap command, which downloads report
+// messages from an IMAP mailbox into ~/.godmarc
+func runFetchIMAP(args []string) error {
+	fs := flag.NewFlagSet("fetch-imap", flag.ContinueOnError)
+	addr := fs.String("addr", "", "IMAP server address as host:port")
+	user := fs.String("user", "", "IMAP user name")
+	mailboxName := fs.String("mailbox", "INBOX", "mailbox to fetch reports from")
+	security := fs.String("security", "tls", "connection security: tls or starttls")
+	moveTo := fs.String("move-to", "", "move handled messages to this mailbox")
+	flagName := fs.String("flag", "", "set this flag on handled messages, e.g. \\Seen")
+	insecure := fs.Bool("insecure", false, "skip TLS certificate verification (for local testing)")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godmarc fetch-imap -addr host:port -user name [flags]")
+		fmt.Fprintln(fs.Output(), "The password is read from the GODMARC_IMAP_PASSWORD environment variable.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if *addr == "" || *user == "" {
+		fs.Usage()
+		return fmt.Errorf("-addr and -user are required")
+	}
+
+	password := os.Getenv("GODMARC_IMAP_PASSWORD")
+	if password == "" {
+		return fmt.Errorf("GODMARC_IMAP_PASSWORD is not set")
+	}
+
+	loader, err := storage.NewReportLoader()
+	if err != nil {
+		return err
+	}
+
+	fetcher := ingest.NewIMAPFetcher(ingest.IMAPConfig{
+		Addr:      *addr,
+		Username:  *user,
+		Password:  password,
+		Mailbox:   *mailboxName,
+		Security:  ingest.Security(*security),
+		TLSConfig: &tls.Config{InsecureSkipVerify: *insecure},
+		MoveTo:    *moveTo,
+		Flag:      *flagName,
+	}, loader)
+
+	result, err := fetcher.Fetch()
+	for _, name := range result.Saved {
+		fmt.Printf("Saved %s\n", name)
+	}
+	for _, failure := range result.Failed {
+		fmt.Fprintf(os.Stderr, "Could not store %s\n", failure)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"Checked %d messages, saved %d, skipped %d, failed %d\n",
+		result.Checked,
+		len(result.Saved),
+		result.Skipped,
+		len(result.Failed),
+	)
+
+	return nil
+}
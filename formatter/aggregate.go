@@ -21,7 +21,14 @@ func FormatAggregatedReport(aggr model.AggregatedReport, width int) string {
 	// Overview
 	sb.WriteString(headerStyle.Render("Aggregated Report") + "\n\n")
 	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Total Reports:"), valueStyle.Render(fmt.Sprintf("%d", aggr.TotalReports))))
+	if aggr.Duplicates > 0 {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Duplicates:"), warnStyle.Render(fmt.Sprintf("%d (not counted)", aggr.Duplicates))))
+	}
 	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Total Records:"), valueStyle.Render(fmt.Sprintf("%d", aggr.TotalRecords))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Total Messages:"), valueStyle.Render(fmt.Sprintf("%d", aggr.TotalMessages))))
+	sb.WriteString(fmt.Sprintf("  %s %s %s\n", labelStyle.Render("DMARC Compliance:"), colorRate(aggr.ComplianceRate()), valueStyle.Render(fmt.Sprintf("(%d of %d messages)", aggr.Compliant.Messages, aggr.TotalMessages))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("DKIM Pass:"), colorRate(aggr.DKIMPassRate())))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("SPF Pass:"), colorRate(aggr.SPFPassRate())))
 	sb.WriteString(fmt.Sprintf("  %s %s to %s\n",
 		labelStyle.Render("Date Range:"),
 		valueStyle.Render(aggr.DateRange.Begin.Format("2006-01-02")),
@@ -29,16 +36,36 @@ func FormatAggregatedReport(aggr model.AggregatedReport, width int) string {
 
 	// Domains table
 	sb.WriteString("\n" + headerStyle.Render("Domains") + "\n\n")
-	domainRows := make([][]string, 0, len(aggr.Domains))
-	for domain, count := range aggr.Domains {
-		domainRows = append(domainRows, []string{domain, fmt.Sprintf("%d", count)})
+	orgDomains := make([]string, 0, len(aggr.Domains))
+	for orgDomain := range aggr.Domains {
+		orgDomains = append(orgDomains, orgDomain)
+	}
+	sort.Strings(orgDomains)
+
+	// Each organizational domain is followed by its policy domains, unless
+	// the policy was only published for the organizational domain itself
+	var domainRows [][]string
+	for _, orgDomain := range orgDomains {
+		domainRows = append(domainRows, []string{orgDomain, "", fmt.Sprintf("%d", aggr.Domains[orgDomain])})
+
+		policyDomains := aggr.PolicyDomains[orgDomain]
+		if _, ok := policyDomains[orgDomain]; ok && len(policyDomains) == 1 {
+			continue
+		}
+		domains := make([]string, 0, len(policyDomains))
+		for domain := range policyDomains {
+			domains = append(domains, domain)
+		}
+		sort.Strings(domains)
+		for _, domain := range domains {
+			domainRows = append(domainRows, []string{"", domain, fmt.Sprintf("%d", policyDomains[domain])})
+		}
 	}
-	sort.Slice(domainRows, func(i, j int) bool { return domainRows[i][0] < domainRows[j][0] })
 
 	dt := ltable.New().
 		Border(lipgloss.NormalBorder()).
 		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
-		Headers("Domain", "Reports").
+		Headers("Org Domain", "Policy Domain", "Reports").
 		Rows(domainRows...).
 		StyleFunc(func(row, col int) lipgloss.Style {
 			if row == ltable.HeaderRow {
@@ -49,40 +76,59 @@ func FormatAggregatedReport(aggr model.AggregatedReport, width int) string {
 
 	sb.WriteString(dt.Render() + "\n")
 
-	// Summary statistics - side by side using lipgloss columns
+	// Summary statistics - side by side if they fit, weighted by messages
 	sb.WriteString("\n" + headerStyle.Render("Summary Statistics") + "\n\n")
 
-	// Build three columns
-	dispCol := buildStatColumn("Dispositions", aggr.Dispositions, true)
-	dkimCol := buildStatColumn("DKIM Results", aggr.DKIMResults, false)
-	spfCol := buildStatColumn("SPF Results", aggr.SPFResults, false)
+	statTables := []string{
+		buildStatColumn("Dispositions", aggr, aggr.Dispositions, true),
+		buildStatColumn("DKIM Results", aggr, aggr.DKIMResults, false),
+		buildStatColumn("SPF Results", aggr, aggr.SPFResults, false),
+	}
 
-	colWidth := (width - 10) / 3
-	if colWidth < 20 {
-		colWidth = 20
+	statsWidth := 0
+	for _, t := range statTables {
+		statsWidth += lipgloss.Width(t) + 2
 	}
+	if statsWidth <= width {
+		spacer := strings.Repeat(" ", 2)
+		sb.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
+			statTables[0], spacer, statTables[1], spacer, statTables[2],
+		) + "\n")
+	} else {
+		sb.WriteString(strings.Join(statTables, "\n\n") + "\n")
+	}
+
+	// Alignment computed from the raw auth results, weighted by messages
+	sb.WriteString("\n" + headerStyle.Render("Identifier Alignment") + "\n\n")
 
-	colStyle := lipgloss.NewStyle().Width(colWidth)
-	sb.WriteString(lipgloss.JoinHorizontal(lipgloss.Top,
-		colStyle.Render(dispCol),
-		colStyle.Render(dkimCol),
-		colStyle.Render(spfCol),
-	) + "\n")
+	alignmentRows := make([][]string, 0, len(aggr.Alignments))
+	for _, e := range sortTallies(aggr.Alignments) {
+		alignmentRows = append(alignmentRows, []string{
+			e.key,
+			fmt.Sprintf("%d", e.tally.Messages),
+			formatPercent(aggr.Share(e.tally)),
+			fmt.Sprintf("%d", e.tally.Records),
+		})
+	}
+
+	at := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers("Alignment", "Messages", "Share", "Records").
+		Rows(alignmentRows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+
+	sb.WriteString(at.Render() + "\n")
 
 	// Top Sources table
 	sb.WriteString("\n" + headerStyle.Render("Top Sources") + "\n\n")
 
-	type sourceCount struct {
-		source string
-		count  int
-	}
-	sourceCounts := make([]sourceCount, 0, len(aggr.Sources))
-	for source, count := range aggr.Sources {
-		sourceCounts = append(sourceCounts, sourceCount{source, count})
-	}
-	sort.Slice(sourceCounts, func(i, j int) bool {
-		return sourceCounts[i].count > sourceCounts[j].count
-	})
+	sourceCounts := sortTallies(aggr.Sources)
 
 	topCount := len(sourceCounts)
 	if topCount > 20 {
@@ -92,15 +138,17 @@ func FormatAggregatedReport(aggr model.AggregatedReport, width int) string {
 	sourceRows := make([][]string, 0, topCount)
 	for i := 0; i < topCount; i++ {
 		sourceRows = append(sourceRows, []string{
-			sourceCounts[i].source,
-			fmt.Sprintf("%d", sourceCounts[i].count),
+			formatSource(sourceCounts[i].key, aggr.Providers[sourceCounts[i].key]),
+			fmt.Sprintf("%d", sourceCounts[i].tally.Messages),
+			formatPercent(aggr.Share(sourceCounts[i].tally)),
+			fmt.Sprintf("%d", sourceCounts[i].tally.Records),
 		})
 	}
 
 	st := ltable.New().
 		Border(lipgloss.NormalBorder()).
 		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
-		Headers("Source IP", "Count").
+		Headers("Source IP", "Messages", "Share", "Records").
 		Rows(sourceRows...).
 		StyleFunc(func(row, col int) lipgloss.Style {
 			if row == ltable.HeaderRow {
@@ -142,17 +190,18 @@ func FormatFailedRecords(aggr model.AggregatedReport, width int) string {
 	for i := 0; i < maxRows; i++ {
 		record := aggr.FailedRecords[i]
 		rows = append(rows, []string{
-			record.SourceIP,
+			formatSource(record.SourceIP, record.Provider),
 			record.Domain,
 			fmt.Sprintf("%d", record.Count),
 			record.Reason,
+			colorShortAlignment(record.Alignment),
 		})
 	}
 
 	t := ltable.New().
 		Border(lipgloss.NormalBorder()).
 		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
-		Headers("Source IP", "Domain", "Count", "Reason").
+		Headers("Source IP", "Domain", "Count", "Reason", "Alignment").
 		Rows(rows...).
 		StyleFunc(func(row, col int) lipgloss.Style {
 			if row == ltable.HeaderRow {
@@ -165,6 +214,10 @@ func FormatFailedRecords(aggr model.AggregatedReport, width int) string {
 			return s
 		})
 
+	// Wrap cells rather than lines if the table is too wide
+	if lipgloss.Width(t.Render()) > width {
+		t.Width(width)
+	}
 	sb.WriteString(t.Render() + "\n")
 
 	if len(aggr.FailedRecords) > 50 {
@@ -174,33 +227,74 @@ func FormatFailedRecords(aggr model.AggregatedReport, width int) string {
 	return sb.String()
 }
 
-func buildStatColumn(title string, data map[string]int, isDisposition bool) string {
-	var sb strings.Builder
-	sb.WriteString(headerStyle.Render(title) + "\n\n")
-
-	entries := make([]struct {
-		key   string
-		count int
-	}, 0, len(data))
-	for k, v := range data {
-		entries = append(entries, struct {
-			key   string
-			count int
-		}{k, v})
-	}
-	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].count > entries[j].count
-	})
-
-	for _, e := range entries {
+// buildStatColumn renders a table of results with their messages, share of
+// all messages and records
+func buildStatColumn(title string, aggr model.AggregatedReport, data map[string]model.Tally, isDisposition bool) string {
+	rows := make([][]string, 0, len(data))
+	for _, e := range sortTallies(data) {
 		var colored string
 		if isDisposition {
 			colored = colorDisposition(e.key)
 		} else {
 			colored = colorResult(e.key)
 		}
-		sb.WriteString(fmt.Sprintf("  %-15s %d\n", colored, e.count))
+		rows = append(rows, []string{
+			colored,
+			fmt.Sprintf("%d", e.tally.Messages),
+			formatPercent(aggr.Share(e.tally)),
+			fmt.Sprintf("%d", e.tally.Records),
+		})
 	}
 
-	return sb.String()
+	t := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers(title, "Messages", "Share", "Records").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+
+	return t.Render()
+}
+
+// keyedTally is an entry of a map of tallies
+type keyedTally struct {
+	key   string
+	tally model.Tally
+}
+
+// sortTallies returns the entries of a map of tallies, most messages first
+func sortTallies(data map[string]model.Tally) []keyedTally {
+	entries := make([]keyedTally, 0, len(data))
+	for k, v := range data {
+		entries = append(entries, keyedTally{k, v})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].tally.Messages != entries[j].tally.Messages {
+			return entries[i].tally.Messages > entries[j].tally.Messages
+		}
+		return entries[i].key < entries[j].key
+	})
+	return entries
+}
+
+// formatPercent formats a fraction from 0 to 1 as a percentage
+func formatPercent(rate float64) string {
+	return fmt.Sprintf("%.1f%%", rate*100)
+}
+
+// colorRate renders a pass rate, colored by how healthy it is
+func colorRate(rate float64) string {
+	switch {
+	case rate >= 0.98:
+		return passStyle.Render(formatPercent(rate))
+	case rate >= 0.9:
+		return warnStyle.Render(formatPercent(rate))
+	default:
+		return failStyle.Render(formatPercent(rate))
+	}
 }
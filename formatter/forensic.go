@@ -0,0 +1,158 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	ltable "github.com/charmbracelet/lipgloss/table"
+	"github.com/huhndev/godmarc/model"
+)
+
+// maxLinkedRecords limits the aggregate records shown per failure report
+const maxLinkedRecords = 10
+
+// maxHeaderLines limits the original header lines shown per failure report
+const maxHeaderLines = 30
+
+// FormatForensicReports formats failure reports for the "Forensic" tab,
+// each followed by the aggregate records seen for the same source IP
+func FormatForensicReports(forensic []model.ForensicReport, reports []model.DMARCReport, width int) string {
+	var sb strings.Builder
+
+	if width < 60 {
+		width = 60
+	}
+
+	sb.WriteString(headerStyle.Render(fmt.Sprintf("Failure Reports (%d)", len(forensic))) + "\n\n")
+
+	if len(forensic) == 0 {
+		sb.WriteString("  No failure reports found. Reporters send them to the ruf= address\n")
+		sb.WriteString("  of your DMARC record; place those messages in ~/.godmarc.\n")
+		return sb.String()
+	}
+
+	index := model.IndexRecordsBySourceIP(reports)
+
+	// Overview table
+	rows := make([][]string, 0, len(forensic))
+	for i, report := range forensic {
+		rows = append(rows, []string{
+			fmt.Sprintf("%d", i+1),
+			report.Date().Format("2006-01-02 15:04"),
+			report.SourceIP,
+			failStyle.Render(report.AuthFailure),
+			report.HeaderFrom,
+			fmt.Sprintf("%d", len(index[report.SourceIP])),
+		})
+	}
+
+	t := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers("#", "Arrival", "Source IP", "Failure", "From", "Aggregate Records").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+
+	sb.WriteString(t.Render() + "\n")
+
+	// Details per report
+	for i, report := range forensic {
+		sb.WriteString("\n" + headerStyle.Render(fmt.Sprintf("Failure Report #%d", i+1)) + "\n\n")
+		writeField(&sb, "Arrival Date:", report.Date().Format("2006-01-02 15:04:05 -0700"))
+		writeField(&sb, "Reporter:", report.Provenance.From)
+		writeField(&sb, "User Agent:", report.UserAgent)
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Auth Failure:"), failStyle.Render(report.AuthFailure)))
+		writeField(&sb, "Source IP:", report.SourceIP)
+		writeField(&sb, "Reported Domain:", report.ReportedDomain)
+		writeField(&sb, "Header From:", report.HeaderFrom)
+		writeField(&sb, "Mail From:", report.OriginalMailFrom)
+		writeField(&sb, "Rcpt To:", strings.Join(report.OriginalRcptTo, ", "))
+		writeField(&sb, "Subject:", report.Subject)
+		writeField(&sb, "Message-ID:", report.MessageID)
+		writeField(&sb, "Delivery Result:", report.DeliveryResult)
+		writeField(&sb, "DKIM Domain:", report.DKIMDomain)
+		writeField(&sb, "DKIM Identity:", report.DKIMIdentity)
+		writeField(&sb, "DKIM Selector:", report.DKIMSelector)
+		writeField(&sb, "SPF DNS:", report.SPFDNS)
+		writeField(&sb, "Alignment:", report.IdentityAlign)
+		for _, ar := range report.AuthResults {
+			writeField(&sb, "Auth Results:", ar)
+		}
+
+		// Aggregate records for the same source IP
+		linked := index[report.SourceIP]
+		sb.WriteString("\n  " + headerStyle.Render(fmt.Sprintf("Aggregate Records from %s (%d)", report.SourceIP, len(linked))) + "\n")
+		if len(linked) == 0 {
+			sb.WriteString("  No aggregate records for this source IP\n")
+		} else {
+			sb.WriteString(formatLinkedRecords(linked) + "\n")
+			if len(linked) > maxLinkedRecords {
+				sb.WriteString(fmt.Sprintf("  ... and %d more records\n", len(linked)-maxLinkedRecords))
+			}
+		}
+
+		// Original headers as sent by the reporter
+		if report.OriginalHeaders != "" {
+			sb.WriteString("\n  " + headerStyle.Render("Original Headers") + "\n")
+			lines := strings.Split(strings.ReplaceAll(report.OriginalHeaders, "\r\n", "\n"), "\n")
+			for j, line := range lines {
+				if j == maxHeaderLines {
+					sb.WriteString(fmt.Sprintf("    ... %d more lines\n", len(lines)-maxHeaderLines))
+					break
+				}
+				sb.WriteString("    " + TruncateString(line, width-6) + "\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// formatLinkedRecords renders aggregate records in a table
+func formatLinkedRecords(linked []model.LinkedRecord) string {
+	maxRows := len(linked)
+	if maxRows > maxLinkedRecords {
+		maxRows = maxLinkedRecords
+	}
+
+	rows := make([][]string, 0, maxRows)
+	for _, lr := range linked[:maxRows] {
+		rows = append(rows, []string{
+			lr.OrgName,
+			lr.DateRange.Begin.Format("2006-01-02"),
+			fmt.Sprintf("%d", lr.Record.Row.Count),
+			colorDisposition(lr.Record.Row.PolicyEvaluated.Disposition),
+			colorResult(lr.Record.Row.PolicyEvaluated.DKIM),
+			colorResult(lr.Record.Row.PolicyEvaluated.SPF),
+			lr.Record.Identifiers.HeaderFrom,
+		})
+	}
+
+	t := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers("Reporter", "Date", "Count", "Disposition", "DKIM", "SPF", "Header From").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+
+	return t.Render()
+}
+
+// writeField writes a labelled value, skipping empty values
+func writeField(sb *strings.Builder, label, value string) {
+	if value == "" {
+		return
+	}
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render(label), valueStyle.Render(value)))
+}
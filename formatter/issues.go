@@ -0,0 +1,188 @@
+package formatter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	ltable "github.com/charmbracelet/lipgloss/table"
+	"github.com/huhndev/godmarc/parser"
+	"github.com/huhndev/godmarc/storage"
+)
+
+// FormatLoadIssues formats the files that were skipped or failed to load
+// for the "Load Issues" tab
+func FormatLoadIssues(results []storage.ParseResult, width int) string {
+	var sb strings.Builder
+
+	if width < 60 {
+		width = 60
+	}
+
+	counts := make(map[storage.LoadStatus]int)
+	var issues []storage.ParseResult
+	var total time.Duration
+	for _, result := range results {
+		counts[result.Status]++
+		total += result.Duration
+		if result.HasIssue() {
+			issues = append(issues, result)
+		}
+	}
+
+	sb.WriteString(headerStyle.Render(fmt.Sprintf("Load Issues (%d)", len(issues))) + "\n\n")
+
+	// Overview
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Files:"), valueStyle.Render(fmt.Sprintf("%d", len(results)))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Loaded:"), passStyle.Render(fmt.Sprintf("%d", counts[storage.StatusLoaded]))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Partially Loaded:"), colorIssueCount(counts[storage.StatusPartial], warnStyle)))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Failed:"), colorIssueCount(counts[storage.StatusFailed], failStyle)))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Skipped:"), colorIssueCount(counts[storage.StatusSkipped], warnStyle)))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Load Time:"), valueStyle.Render(formatDuration(total))))
+
+	if len(issues) == 0 {
+		sb.WriteString("\n  All files loaded without problems.\n")
+		return sb.String()
+	}
+
+	// Failures first, skipped files last, otherwise in directory order
+	sort.SliceStable(issues, func(i, j int) bool {
+		return statusRank(issues[i].Status) < statusRank(issues[j].Status)
+	})
+
+	// Problems grouped by kind
+	kinds := make(map[string]int)
+	for _, result := range issues {
+		for _, err := range result.Errors() {
+			kinds[issueKind(result, err)]++
+		}
+	}
+	kindNames := make([]string, 0, len(kinds))
+	for kind := range kinds {
+		kindNames = append(kindNames, kind)
+	}
+	sort.Slice(kindNames, func(i, j int) bool {
+		if kinds[kindNames[i]] != kinds[kindNames[j]] {
+			return kinds[kindNames[i]] > kinds[kindNames[j]]
+		}
+		return kindNames[i] < kindNames[j]
+	})
+
+	kindRows := make([][]string, 0, len(kindNames))
+	for _, kind := range kindNames {
+		kindRows = append(kindRows, []string{kind, fmt.Sprintf("%d", kinds[kind])})
+	}
+
+	sb.WriteString("\n" + headerStyle.Render("By Kind") + "\n\n")
+	sb.WriteString(issueTable(kindRows, "Kind", "Count").Render() + "\n")
+
+	// Files with issues
+	fileRows := make([][]string, 0, len(issues))
+	for _, result := range issues {
+		fileRows = append(fileRows, []string{
+			TruncateString(result.Filename, 40),
+			colorStatus(result.Status),
+			formatSize(result.Size),
+			fmt.Sprintf("%d", result.Reports),
+			fmt.Sprintf("%d", len(result.Errors())),
+			formatDuration(result.Duration),
+		})
+	}
+
+	sb.WriteString("\n" + headerStyle.Render("Files") + "\n\n")
+	sb.WriteString(issueTable(fileRows, "File", "Status", "Size", "Reports", "Problems", "Time").Render() + "\n")
+
+	// Full error messages, wrapped to the screen
+	wrap := lipgloss.NewStyle().Width(width - 6)
+	sb.WriteString("\n" + headerStyle.Render("Details") + "\n")
+	for _, result := range issues {
+		sb.WriteString(fmt.Sprintf("\n  %s %s\n", valueStyle.Render(result.Filename), colorStatus(result.Status)))
+		for _, err := range result.Errors() {
+			lines := strings.Split(wrap.Render(err.Error()), "\n")
+			for i, line := range lines {
+				prefix := "    - "
+				if i > 0 {
+					prefix = "      "
+				}
+				sb.WriteString(prefix + strings.TrimRight(line, " ") + "\n")
+			}
+		}
+	}
+
+	return sb.String()
+}
+
+// issueTable renders a table in the style of the other tabs
+func issueTable(rows [][]string, headers ...string) *ltable.Table {
+	return ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers(headers...).
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+}
+
+// issueKind names the kind of problem for grouping. Skipped files are
+// grouped by their reason.
+func issueKind(result storage.ParseResult, err error) string {
+	if result.Status == storage.StatusSkipped {
+		return "skipped: " + err.Error()
+	}
+	return parser.ErrorCategory(err)
+}
+
+// statusRank orders load statuses by severity
+func statusRank(status storage.LoadStatus) int {
+	switch status {
+	case storage.StatusFailed:
+		return 0
+	case storage.StatusPartial:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// colorStatus colors a load status
+func colorStatus(status storage.LoadStatus) string {
+	switch status {
+	case storage.StatusLoaded:
+		return passStyle.Render(string(status))
+	case storage.StatusFailed:
+		return failStyle.Render(string(status))
+	default:
+		return warnStyle.Render(string(status))
+	}
+}
+
+// colorIssueCount renders a count in style if it is not zero
+func colorIssueCount(n int, style lipgloss.Style) string {
+	if n == 0 {
+		return valueStyle.Render("0")
+	}
+	return style.Render(fmt.Sprintf("%d", n))
+}
+
+// formatSize formats a file size in bytes for humans
+func formatSize(n int64) string {
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
+
+// formatDuration formats a load duration with millisecond precision
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%.1f ms", float64(d.Microseconds())/1000)
+}
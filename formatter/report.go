@@ -48,7 +48,7 @@ func colorResult(result string) string {
 
 func colorDisposition(disp string) string {
 	switch disp {
-	case "none":
+	case "none", "pass":
 		return passStyle.Render(disp)
 	case "reject":
 		return failStyle.Render(disp)
@@ -59,6 +59,63 @@ func colorDisposition(disp string) string {
 	}
 }
 
+// formatSource renders a source IP address with the known email service it
+// belongs to, if any
+func formatSource(ip, provider string) string {
+	if provider == "" {
+		return ip
+	}
+	return ip + " (" + provider + ")"
+}
+
+// colorAlignment renders an alignment class: passing classes in green,
+// passes that only failed alignment in yellow and the rest in red
+func colorAlignment(class model.AlignmentClass) string {
+	switch class {
+	case model.AlignedBoth, model.AlignedDKIM, model.AlignedSPF:
+		return passStyle.Render(class.String())
+	case model.UnalignedBoth, model.UnalignedDKIM, model.UnalignedSPF:
+		return warnStyle.Render(class.String())
+	default:
+		return failStyle.Render(class.String())
+	}
+}
+
+// colorShortAlignment renders an alignment class in a few words, colored
+// like colorAlignment
+func colorShortAlignment(class model.AlignmentClass) string {
+	switch class {
+	case model.AlignedBoth:
+		return passStyle.Render("DKIM+SPF aligned")
+	case model.AlignedDKIM:
+		return passStyle.Render("DKIM aligned")
+	case model.AlignedSPF:
+		return passStyle.Render("SPF aligned")
+	case model.UnalignedBoth:
+		return warnStyle.Render("DKIM+SPF unaligned")
+	case model.UnalignedDKIM:
+		return warnStyle.Render("DKIM unaligned")
+	case model.UnalignedSPF:
+		return warnStyle.Render("SPF unaligned")
+	case model.NoAuthPass:
+		return failStyle.Render("no pass")
+	default:
+		return failStyle.Render("no From")
+	}
+}
+
+// formatAligned renders whether an auth result is aligned with the header
+// From domain
+func formatAligned(result, domain, headerFrom, mode string) string {
+	if !model.IsAligned(domain, headerFrom, mode) {
+		return failStyle.Render("no")
+	}
+	if result != "pass" {
+		return warnStyle.Render("yes")
+	}
+	return passStyle.Render("yes")
+}
+
 // FormatReport formats a single DMARC report for display
 func FormatReport(report model.DMARCReport, width int) string {
 	var sb strings.Builder
@@ -67,6 +124,11 @@ func FormatReport(report model.DMARCReport, width int) string {
 		width = 60
 	}
 
+	// Leniently parsed reports are missing data
+	if report.Degraded {
+		sb.WriteString(formatProblems(report, width) + "\n")
+	}
+
 	// Report metadata
 	sb.WriteString(headerStyle.Render("Report Metadata") + "\n\n")
 	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Organization:"), valueStyle.Render(report.ReportMetadata.OrgName)))
@@ -76,6 +138,23 @@ func FormatReport(report model.DMARCReport, width int) string {
 		labelStyle.Render("Date Range:"),
 		valueStyle.Render(report.ReportMetadata.DateRange.Begin.Format("2006-01-02")),
 		valueStyle.Render(report.ReportMetadata.DateRange.End.Format("2006-01-02"))))
+	if report.Schema != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Format:"), valueStyle.Render(string(report.Schema))))
+	}
+	if report.ReportMetadata.Generator != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Generator:"), valueStyle.Render(report.ReportMetadata.Generator)))
+	}
+	if report.ReportMetadata.ExtraContact != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Extra Contact:"), valueStyle.Render(report.ReportMetadata.ExtraContact)))
+	}
+	if !report.Provenance.IsZero() {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Received:"), valueStyle.Render(report.Provenance.Date.Format("2006-01-02 15:04:05 -0700"))))
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Sent By:"), valueStyle.Render(report.Provenance.From)))
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Message-ID:"), valueStyle.Render(report.Provenance.MessageID)))
+	}
+	for _, reportErr := range report.ReportMetadata.Errors {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Reporter Error:"), failStyle.Render(reportErr)))
+	}
 
 	// Policy published
 	sb.WriteString("\n" + headerStyle.Render("Published Policy") + "\n\n")
@@ -84,10 +163,32 @@ func FormatReport(report model.DMARCReport, width int) string {
 	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("SPF Alignment:"), valueStyle.Render(report.PolicyPublished.ASPF)))
 	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Policy:"), valueStyle.Render(report.PolicyPublished.P)))
 	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Subdomain Policy:"), valueStyle.Render(report.PolicyPublished.SP)))
-	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Percentage:"), valueStyle.Render(fmt.Sprintf("%d%%", report.PolicyPublished.PCT))))
+	if report.PolicyPublished.NP != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Non-Existent Policy:"), valueStyle.Render(report.PolicyPublished.NP)))
+	}
+	// DMARCbis dropped pct in favor of the testing flag
+	if report.Schema != model.SchemaDMARCbis {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Percentage:"), valueStyle.Render(fmt.Sprintf("%d%%", report.PolicyPublished.PCT))))
+	}
+	if report.PolicyPublished.Testing != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Testing:"), valueStyle.Render(report.PolicyPublished.Testing)))
+	}
+	if report.PolicyPublished.PSD != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("PSD:"), valueStyle.Render(report.PolicyPublished.PSD)))
+	}
+	if report.PolicyPublished.DiscoveryMethod != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Discovery Method:"), valueStyle.Render(report.PolicyPublished.DiscoveryMethod)))
+	}
+	if report.PolicyPublished.FO != "" {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Failure Options:"), valueStyle.Render(report.PolicyPublished.FO)))
+	}
 
 	// Records table
-	sb.WriteString("\n" + headerStyle.Render(fmt.Sprintf("Records (%d)", len(report.Records))) + "\n\n")
+	recordsTitle := fmt.Sprintf("Records (%d)", len(report.Records))
+	if report.Degraded {
+		recordsTitle += " " + failStyle.Render("incomplete")
+	}
+	sb.WriteString("\n" + headerStyle.Render(recordsTitle) + "\n\n")
 
 	tableWidth := width - 4
 	if tableWidth < 60 {
@@ -97,11 +198,12 @@ func FormatReport(report model.DMARCReport, width int) string {
 	rows := make([][]string, 0, len(report.Records))
 	for _, record := range report.Records {
 		rows = append(rows, []string{
-			record.Row.SourceIP,
+			formatSource(record.Row.SourceIP, record.Provider()),
 			fmt.Sprintf("%d", record.Row.Count),
 			colorDisposition(record.Row.PolicyEvaluated.Disposition),
 			colorResult(record.Row.PolicyEvaluated.DKIM),
 			colorResult(record.Row.PolicyEvaluated.SPF),
+			warnStyle.Render(record.Row.PolicyEvaluated.OverrideTypes()),
 			record.Identifiers.HeaderFrom,
 		})
 	}
@@ -109,7 +211,7 @@ func FormatReport(report model.DMARCReport, width int) string {
 	t := ltable.New().
 		Border(lipgloss.NormalBorder()).
 		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
-		Headers("Source IP", "Count", "Disposition", "DKIM", "SPF", "Header From").
+		Headers("Source IP", "Count", "Disposition", "DKIM", "SPF", "Override", "Header From").
 		Rows(rows...).
 		StyleFunc(func(row, col int) lipgloss.Style {
 			if row == ltable.HeaderRow {
@@ -124,6 +226,49 @@ func FormatReport(report model.DMARCReport, width int) string {
 	for i, record := range report.Records {
 		sb.WriteString("\n" + headerStyle.Render(fmt.Sprintf("Record #%d Auth Details", i+1)) + "\n\n")
 
+		// Alignment computed from the auth results, and whether the
+		// reporter came to the same verdict
+		alignment := record.Alignment(report.PolicyPublished).Class()
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Alignment:"), colorAlignment(alignment)))
+		evaluated := record.Row.PolicyEvaluated
+		if alignment != model.NoHeaderFrom &&
+			alignment.Passes() != (evaluated.DKIM == "pass" || evaluated.SPF == "pass") {
+			sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Reporter Verdict:"),
+				warnStyle.Render(fmt.Sprintf("DKIM %s, SPF %s (differs)", evaluated.DKIM, evaluated.SPF))))
+		}
+
+		// The subdomain policies apply below the organizational domain.
+		// Whether np applies depends on the domain not existing, which
+		// the report does not say.
+		policy := report.PolicyPublished
+		if policy.IsSubdomain(record.Identifiers.HeaderFrom) {
+			applied := policy.PolicyFor(record.Identifiers.HeaderFrom)
+			npDiffers := policy.NP != "" && policy.NP != applied
+			note := applied + " (subdomain policy)"
+			if npDiffers {
+				note += fmt.Sprintf(", %s if the domain does not exist", policy.NP)
+			}
+			if applied != policy.P || npDiffers {
+				sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Applied Policy:"), valueStyle.Render(note)))
+			}
+		}
+
+		// Identifiers and policy overrides
+		if record.Identifiers.EnvelopeFrom != "" {
+			sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Envelope From:"), valueStyle.Render(record.Identifiers.EnvelopeFrom)))
+		}
+		if record.Identifiers.EnvelopeTo != "" {
+			sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Envelope To:"), valueStyle.Render(record.Identifiers.EnvelopeTo)))
+		}
+		for _, reason := range record.Row.PolicyEvaluated.Reasons {
+			override := warnStyle.Render(reason.Type)
+			if reason.Comment != "" {
+				override += " " + valueStyle.Render(reason.Comment)
+			}
+			sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Policy Override:"), override))
+		}
+		sb.WriteString("\n")
+
 		// DKIM authentication
 		if len(record.AuthResults.DKIM) > 0 {
 			dkimRows := make([][]string, 0, len(record.AuthResults.DKIM))
@@ -131,14 +276,16 @@ func FormatReport(report model.DMARCReport, width int) string {
 				dkimRows = append(dkimRows, []string{
 					dkim.Domain,
 					colorResult(dkim.Result),
+					formatAligned(dkim.Result, dkim.Domain, record.Identifiers.HeaderFrom, report.PolicyPublished.ADKIM),
 					dkim.Selector,
+					dkim.HumanResult,
 				})
 			}
 
 			dt := ltable.New().
 				Border(lipgloss.NormalBorder()).
 				BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
-				Headers("DKIM Domain", "Result", "Selector").
+				Headers("DKIM Domain", "Result", "Aligned", "Selector", "Details").
 				Rows(dkimRows...).
 				StyleFunc(func(row, col int) lipgloss.Style {
 					if row == ltable.HeaderRow {
@@ -157,14 +304,16 @@ func FormatReport(report model.DMARCReport, width int) string {
 				spfRows = append(spfRows, []string{
 					spf.Domain,
 					colorResult(spf.Result),
+					formatAligned(spf.Result, spf.Domain, record.Identifiers.HeaderFrom, report.PolicyPublished.ASPF),
 					spf.Scope,
+					spf.HumanResult,
 				})
 			}
 
 			st := ltable.New().
 				Border(lipgloss.NormalBorder()).
 				BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
-				Headers("SPF Domain", "Result", "Scope").
+				Headers("SPF Domain", "Result", "Aligned", "Scope", "Details").
 				Rows(spfRows...).
 				StyleFunc(func(row, col int) lipgloss.Style {
 					if row == ltable.HeaderRow {
@@ -180,6 +329,47 @@ func FormatReport(report model.DMARCReport, width int) string {
 	return sb.String()
 }
 
+// formatProblems formats the warning and problem list of a report that was
+// only partially parsed
+func formatProblems(report model.DMARCReport, width int) string {
+	var sb strings.Builder
+
+	sb.WriteString(failStyle.Render("⚠ Incomplete data: this report could only be parsed partially") + "\n")
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Problems:"), valueStyle.Render(fmt.Sprintf("%d", len(report.Problems)))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Records Skipped:"), valueStyle.Render(fmt.Sprintf("%d", report.SkippedRecords()))))
+	sb.WriteString("  Counts and results below only cover the records that could be read.\n\n")
+
+	sb.WriteString(headerStyle.Render("Parse Problems") + "\n\n")
+
+	rows := make([][]string, 0, len(report.Problems))
+	for _, problem := range report.Problems {
+		record := "-"
+		if problem.Record > 0 {
+			record = fmt.Sprintf("%d", problem.Record)
+		}
+		if problem.Skipped {
+			record += " " + failStyle.Render("skipped")
+		}
+		rows = append(rows, []string{record, problem.Field, TruncateString(problem.Msg, max(width-len(problem.Field)-30, 20))})
+	}
+
+	t := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers("Record", "Field", "Problem").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+
+	sb.WriteString(t.Render() + "\n")
+
+	return sb.String()
+}
+
 // TruncateString truncates a string to maxLen, adding ellipsis if needed
 func TruncateString(s string, maxLen int) string {
 	if len(s) <= maxLen {
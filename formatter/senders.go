@@ -0,0 +1,120 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	ltable "github.com/charmbracelet/lipgloss/table"
+	"github.com/huhndev/godmarc/model"
+)
+
+// maxSenders is the number of senders listed in the "Senders" tab
+const maxSenders = 100
+
+// FormatSenders formats the sending services seen in aggregate reports for
+// the "Senders" tab
+func FormatSenders(senders []model.Sender, width int) string {
+	var sb strings.Builder
+
+	if width < 60 {
+		width = 60
+	}
+
+	sb.WriteString(headerStyle.Render(fmt.Sprintf("Senders (%d)", len(senders))) + "\n\n")
+
+	if len(senders) == 0 {
+		sb.WriteString("  No senders found in the aggregate reports.\n")
+		return sb.String()
+	}
+
+	// Overview. Senders reported with a message count of zero have no
+	// alignment to count.
+	messages, aligned, partly, failing, empty := 0, 0, 0, 0, 0
+	for _, sender := range senders {
+		messages += sender.Messages
+		switch {
+		case sender.Messages == 0:
+			empty++
+		case sender.Compliant == sender.Messages:
+			aligned++
+		case sender.Compliant == 0:
+			failing++
+		default:
+			partly++
+		}
+	}
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Total Messages:"), valueStyle.Render(fmt.Sprintf("%d", messages))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Fully Aligned:"), passStyle.Render(fmt.Sprintf("%d", aligned))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Partly Aligned:"), colorIssueCount(partly, warnStyle)))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Never Aligned:"), colorIssueCount(failing, failStyle)))
+	if empty > 0 {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Without Messages:"), valueStyle.Render(fmt.Sprintf("%d", empty))))
+	}
+	sb.WriteString("\n")
+
+	maxRows := len(senders)
+	if maxRows > maxSenders {
+		maxRows = maxSenders
+	}
+
+	rows := make([][]string, 0, maxRows)
+	for _, sender := range senders[:maxRows] {
+		pass := "n/a"
+		if sender.Messages > 0 {
+			pass = colorRate(sender.PassRate())
+		}
+		rows = append(rows, []string{
+			orNone(sender.DKIMDomain),
+			orNone(sender.SPFDomain),
+			sender.Network,
+			fmt.Sprintf("%d", sender.Messages),
+			pass,
+			colorShortAlignment(sender.Alignment()),
+			sender.FirstSeen.Format("2006-01-02"),
+			sender.LastSeen.Format("2006-01-02"),
+			formatReporters(sender.Reporters),
+		})
+	}
+
+	t := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers("DKIM Domain", "SPF Domain", "Network", "Messages", "Pass", "Alignment", "First Seen", "Last Seen", "Reporters").
+		Rows(rows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			return lipgloss.NewStyle().Padding(0, 1)
+		})
+
+	// Wrap cells rather than lines if the table is too wide
+	if lipgloss.Width(t.Render()) > width {
+		t.Width(width)
+	}
+	sb.WriteString(t.Render() + "\n")
+
+	if len(senders) > maxSenders {
+		sb.WriteString(fmt.Sprintf("\n  ... and %d more senders\n", len(senders)-maxSenders))
+	}
+
+	return sb.String()
+}
+
+// orNone returns s, or a dash if it is empty
+func orNone(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// formatReporters lists the first reporters and how many more there are
+func formatReporters(reporters []string) string {
+	const shown = 2
+	if len(reporters) <= shown {
+		return strings.Join(reporters, ", ")
+	}
+	return fmt.Sprintf("%s +%d", strings.Join(reporters[:shown], ", "), len(reporters)-shown)
+}
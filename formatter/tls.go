@@ -0,0 +1,118 @@
+package formatter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	ltable "github.com/charmbracelet/lipgloss/table"
+	"github.com/huhndev/godmarc/model"
+)
+
+// FormatTLSReports formats SMTP TLS reports for the "TLS" tab
+func FormatTLSReports(reports []model.TLSReport, width int) string {
+	var sb strings.Builder
+
+	if width < 60 {
+		width = 60
+	}
+
+	sb.WriteString(headerStyle.Render("SMTP TLS Reports") + "\n\n")
+
+	if len(reports) == 0 {
+		sb.WriteString("  No TLS reports found. Reporters send them to the rua= address\n")
+		sb.WriteString("  of your _smtp._tls TXT record; place them in ~/.godmarc.\n")
+		return sb.String()
+	}
+
+	aggr := model.AggregateTLSReports(reports)
+
+	// Overview
+	total := aggr.Successful + aggr.Failed
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Total Reports:"), valueStyle.Render(fmt.Sprintf("%d", aggr.TotalReports))))
+	sb.WriteString(fmt.Sprintf("  %s %s to %s\n",
+		labelStyle.Render("Date Range:"),
+		valueStyle.Render(aggr.DateRange.Begin.Format("2006-01-02")),
+		valueStyle.Render(aggr.DateRange.End.Format("2006-01-02"))))
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Successful:"), passStyle.Render(fmt.Sprintf("%d", aggr.Successful))))
+	failed := fmt.Sprintf("%d", aggr.Failed)
+	if aggr.Failed > 0 {
+		failed = failStyle.Render(failed)
+	}
+	sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Failed:"), failed))
+	if total > 0 {
+		sb.WriteString(fmt.Sprintf("  %s %s\n", labelStyle.Render("Success Rate:"), valueStyle.Render(fmt.Sprintf("%.1f%%", float64(aggr.Successful)*100/float64(total)))))
+	}
+
+	// Sessions per policy
+	sb.WriteString("\n" + headerStyle.Render("Policies") + "\n\n")
+	// The other columns and the borders take 46 characters
+	domainWidth := max(width-46, 20)
+	policyRows := make([][]string, 0, len(aggr.Policies))
+	for _, p := range aggr.Policies {
+		policyRows = append(policyRows, []string{
+			TruncateString(p.PolicyDomain, domainWidth),
+			p.PolicyType,
+			fmt.Sprintf("%d", p.Successful),
+			fmt.Sprintf("%d", p.Failed),
+		})
+	}
+
+	pt := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers("Policy Domain", "Type", "Successful", "Failed").
+		Rows(policyRows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			s := lipgloss.NewStyle().Padding(0, 1)
+			if col == 3 && row >= 0 && aggr.Policies[row].Failed > 0 {
+				return s.Foreground(lipgloss.Color("#FF4040"))
+			}
+			return s
+		})
+
+	sb.WriteString(pt.Render() + "\n")
+
+	// Failures per receiving MTA
+	sb.WriteString("\n" + headerStyle.Render(fmt.Sprintf("Failures by Receiving MTA (%d)", len(aggr.Failures))) + "\n\n")
+
+	if len(aggr.Failures) == 0 {
+		sb.WriteString(passStyle.Render("  No failed sessions!") + "\n")
+		return sb.String()
+	}
+
+	// The MTA and reporters share what the result type and count leave
+	textWidth := max((width-55)/2, 20)
+	failureRows := make([][]string, 0, len(aggr.Failures))
+	for _, f := range aggr.Failures {
+		failureRows = append(failureRows, []string{
+			TruncateString(f.ReceivingMX, textWidth),
+			f.ResultType,
+			fmt.Sprintf("%d", f.Failed),
+			TruncateString(strings.Join(f.Reporters, ", "), textWidth),
+		})
+	}
+
+	ft := ltable.New().
+		Border(lipgloss.NormalBorder()).
+		BorderStyle(lipgloss.NewStyle().Foreground(lipgloss.Color("#444444"))).
+		Headers("Receiving MTA", "Result Type", "Failed Sessions", "Reporters").
+		Rows(failureRows...).
+		StyleFunc(func(row, col int) lipgloss.Style {
+			if row == ltable.HeaderRow {
+				return lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#FF5F87")).Padding(0, 1)
+			}
+			s := lipgloss.NewStyle().Padding(0, 1)
+			if col == 1 && row >= 0 {
+				return s.Foreground(lipgloss.Color("#FF4040"))
+			}
+			return s
+		})
+
+	sb.WriteString(ft.Render() + "\n")
+
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	"github.com/huhndev/godmarc/storage"
+)
+
+// runImportDB implements the import-db command, which copies the reports
+// in ~/.godmarc into a database file
+func runImportDB(args []string) error {
+	fs := flag.NewFlagSet("import-db", flag.ContinueOnError)
+	path := fs.String("db", "", "database file to import into, created if missing")
+	lenient := fs.Bool("lenient", false, "keep the readable records of partially malformed reports")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godmarc import-db -db file [flags]")
+		fmt.Fprintln(fs.Output(), "Aggregate reports already in the database are skipped.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if *path == "" {
+		fs.Usage()
+		return fmt.Errorf("-db is required")
+	}
+
+	loader, err := storage.NewReportLoader()
+	if err != nil {
+		return err
+	}
+	loader.Lenient = *lenient
+
+	set, err := loader.LoadAll()
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.OpenDB(*path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	duplicates, err := db.Store(set)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"Imported %d aggregate, %d failure and %d TLS reports into %s\n",
+		len(set.DMARC)-duplicates,
+		len(set.Forensic),
+		len(set.TLS),
+		*path,
+	)
+	if duplicates > 0 {
+		fmt.Printf("Skipped %d aggregate reports already stored\n", duplicates)
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package ingest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/huhndev/godmarc/storage"
+)
+
+// DefaultMaxUploadBytes is the default size limit for uploaded reports
+const DefaultMaxUploadBytes = 25 * 1024 * 1024
+
+// HTTPConfig configures the HTTP ingest endpoint
+type HTTPConfig struct {
+	// Addr is the address to listen on, e.g. ":8080"
+	Addr string
+	// MaxUploadBytes limits the size of an upload, DefaultMaxUploadBytes if
+	// zero
+	MaxUploadBytes int64
+	// Token, if set, must be sent as "Authorization: Bearer <token>"
+	Token string
+	// Log receives a line per accepted or rejected upload if set
+	Log *log.Logger
+}
+
+// HTTPReceiver accepts report uploads over HTTP and stores valid reports
+type HTTPReceiver struct {
+	config HTTPConfig
+	loader *storage.ReportLoader
+	count  atomic.Uint64
+}
+
+// uploadResponse is the JSON body of a successful upload
+type uploadResponse struct {
+	Stored  string           `json:"stored"`
+	Reports []uploadedReport `json:"reports"`
+}
+
+// uploadedReport summarizes one accepted report
+type uploadedReport struct {
+	OrgName  string `json:"org_name"`
+	ReportID string `json:"report_id"`
+	Domain   string `json:"domain"`
+	Records  int    `json:"records"`
+}
+
+// errorResponse is the JSON body of a rejected request
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+// errorBody describes why a request was rejected
+type errorBody struct {
+	Code    string   `json:"code"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
+// NewHTTPReceiver creates an HTTPReceiver that saves into loader's
+// directory
+func NewHTTPReceiver(
+	config HTTPConfig,
+	loader *storage.ReportLoader,
+) *HTTPReceiver {
+	if config.MaxUploadBytes == 0 {
+		config.MaxUploadBytes = DefaultMaxUploadBytes
+	}
+	return &HTTPReceiver{config: config, loader: loader}
+}
+
+// Handler returns the HTTP handler serving POST /reports
+func (r *HTTPReceiver) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/reports", r.handleUpload)
+	return mux
+}
+
+// ListenAndServe serves the ingest endpoint on the configured address
+func (r *HTTPReceiver) ListenAndServe() error {
+	server := &http.Server{
+		Addr:              r.config.Addr,
+		Handler:           r.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       5 * time.Minute,
+	}
+	return server.ListenAndServe()
+}
+
+// handleUpload validates an uploaded xml, gzip or zip body and stores it
+func (r *HTTPReceiver) handleUpload(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeError(w, http.StatusMethodNotAllowed, "method_not_allowed",
+			"only POST is supported", nil)
+		return
+	}
+
+	if !r.authorized(req) {
+		writeError(w, http.StatusUnauthorized, "unauthorized",
+			"missing or invalid bearer token", nil)
+		return
+	}
+
+	body := http.MaxBytesReader(w, req.Body, r.config.MaxUploadBytes)
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "too_large",
+				fmt.Sprintf("upload exceeds %d bytes", maxErr.Limit), nil)
+			return
+		}
+		writeError(w, http.StatusBadRequest, "read_failed",
+			"could not read request body", nil)
+		return
+	}
+
+	if len(data) == 0 {
+		writeError(w, http.StatusBadRequest, "empty_body",
+			"request body is empty", nil)
+		return
+	}
+
+	name := fmt.Sprintf(
+		"upload-%d-%d%s",
+		time.Now().Unix(),
+		r.count.Add(1),
+		storage.DetectFormat(data),
+	)
+
+	reports, errs := r.loader.ParseReportData(data, name)
+	if len(errs) > 0 || len(reports) == 0 {
+		details := make([]string, 0, len(errs))
+		for _, err := range errs {
+			details = append(details, err.Error())
+		}
+		if len(details) == 0 {
+			details = append(details, "upload contains no report")
+		}
+		r.logf("rejected upload from %s: %s", req.RemoteAddr,
+			strings.Join(details, "; "))
+		writeError(w, http.StatusUnprocessableEntity, "invalid_report",
+			"upload is not a valid DMARC aggregate report", details)
+		return
+	}
+
+	stored, err := r.loader.SaveReport(name, data)
+	if err != nil {
+		r.logf("could not store upload from %s: %v", req.RemoteAddr, err)
+		writeError(w, http.StatusInternalServerError, "store_failed",
+			"could not store report", nil)
+		return
+	}
+
+	resp := uploadResponse{Stored: stored}
+	for _, report := range reports {
+		resp.Reports = append(resp.Reports, uploadedReport{
+			OrgName:  report.ReportMetadata.OrgName,
+			ReportID: report.ReportMetadata.ReportID,
+			Domain:   report.PolicyPublished.Domain,
+			Records:  len(report.Records),
+		})
+	}
+
+	r.logf("stored upload from %s as %s", req.RemoteAddr, stored)
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// authorized checks the bearer token if one is configured
+func (r *HTTPReceiver) authorized(req *http.Request) bool {
+	if r.config.Token == "" {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.config.Token)) == 1
+}
+
+// logf logs a message if logging is enabled
+func (r *HTTPReceiver) logf(format string, args ...any) {
+	if r.config.Log != nil {
+		r.config.Log.Printf(format, args...)
+	}
+}
+
+// writeError sends a structured JSON error
+func writeError(
+	w http.ResponseWriter,
+	status int,
+	code string,
+	message string,
+	details []string,
+) {
+	writeJSON(w, status, errorResponse{Error: errorBody{
+		Code:    code,
+		Message: message,
+		Details: details,
+	}})
+}
+
+// writeJSON sends v as a JSON response
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
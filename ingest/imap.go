@@ -0,0 +1,354 @@
+// Package ingest delivers reports from mail and network sources into the
+// report store
+package ingest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/huhndev/godmarc/mailbox"
+	"github.com/huhndev/godmarc/storage"
+)
+
+// Security selects how an IMAP connection is protected
+type Security string
+
+const (
+	// SecurityTLS connects with implicit TLS, usually on port 993
+	SecurityTLS Security = "tls"
+	// SecuritySTARTTLS upgrades a plain connection, usually on port 143
+	SecuritySTARTTLS Security = "starttls"
+)
+
+// IMAPConfig configures an IMAP report source
+type IMAPConfig struct {
+	// Addr is the server address as host:port
+	Addr     string
+	Username string
+	Password string
+	// Mailbox is the folder to search, INBOX if empty
+	Mailbox  string
+	Security Security
+	// TLSConfig overrides the TLS settings, e.g. to trust a local test
+	// server. The server name is derived from Addr if unset.
+	TLSConfig *tls.Config
+	// MoveTo optionally names a folder handled messages are moved to
+	MoveTo string
+	// Flag optionally names a flag set on handled messages, e.g. \Seen
+	Flag string
+}
+
+// IMAPResult summarizes an IMAP fetch
+type IMAPResult struct {
+	Checked int
+	Saved   []string
+	Skipped int
+	// Failed lists messages that could not be stored. Their UIDs are not
+	// recorded, so the next fetch tries them again.
+	Failed []string
+}
+
+// IMAPFetcher downloads report messages from an IMAP mailbox into the
+// report store
+type IMAPFetcher struct {
+	Config IMAPConfig
+	Loader *storage.ReportLoader
+}
+
+// NewIMAPFetcher creates an IMAPFetcher that saves into loader's directory
+func NewIMAPFetcher(
+	config IMAPConfig,
+	loader *storage.ReportLoader,
+) *IMAPFetcher {
+	if config.Mailbox == "" {
+		config.Mailbox = "INBOX"
+	}
+	return &IMAPFetcher{Config: config, Loader: loader}
+}
+
+// Fetch logs into the mailbox, saves every not yet processed message that
+// carries a report attachment and applies the configured move or flag
+func (f *IMAPFetcher) Fetch() (IMAPResult, error) {
+	var result IMAPResult
+
+	c, err := f.connect()
+	if err != nil {
+		return result, err
+	}
+	defer c.Logout()
+
+	if err := c.Login(f.Config.Username, f.Config.Password); err != nil {
+		return result, fmt.Errorf("IMAP login failed: %w", err)
+	}
+
+	status, err := c.Select(f.Config.Mailbox, false)
+	if err != nil {
+		return result, fmt.Errorf(
+			"could not select mailbox %s: %w",
+			f.Config.Mailbox,
+			err,
+		)
+	}
+
+	stateKey := f.Config.Username + "@" + f.Config.Addr + "/" +
+		f.Config.Mailbox
+	state, err := loadIMAPState(f.statePath())
+	if err != nil {
+		return result, err
+	}
+	seen := state.mailbox(stateKey, status.UidValidity)
+
+	if status.Messages == 0 {
+		return result, nil
+	}
+
+	uids, err := c.UidSearch(imap.NewSearchCriteria())
+	if err != nil {
+		return result, fmt.Errorf("IMAP search failed: %w", err)
+	}
+	result.Checked = len(uids)
+
+	pending := new(imap.SeqSet)
+	for _, uid := range uids {
+		if !seen.has(uid) {
+			pending.AddNum(uid)
+		}
+	}
+	if pending.Empty() {
+		return result, nil
+	}
+
+	candidates, err := findReportMessages(c, pending)
+	if err != nil {
+		return result, err
+	}
+
+	handled := new(imap.SeqSet)
+	for _, uid := range uids {
+		if !pending.Contains(uid) {
+			continue
+		}
+		if !candidates[uid] {
+			// Remember messages without reports so they are not
+			// inspected again
+			seen.add(uid)
+			result.Skipped++
+			continue
+		}
+
+		raw, err := fetchMessage(c, uid)
+		if err != nil {
+			// Persist progress made so far before giving up
+			_ = state.save(f.statePath())
+			return result, err
+		}
+
+		name := fmt.Sprintf("imap-%d-%d.eml", status.UidValidity, uid)
+		name, err = storeMessage(f.Loader, name, raw)
+		if err != nil {
+			result.Failed = append(
+				result.Failed,
+				fmt.Sprintf("message %d: %v", uid, err),
+			)
+			continue
+		}
+
+		seen.add(uid)
+		if name == "" {
+			result.Skipped++
+			continue
+		}
+
+		result.Saved = append(result.Saved, name)
+		handled.AddNum(uid)
+	}
+
+	if err := state.save(f.statePath()); err != nil {
+		return result, err
+	}
+
+	if handled.Empty() {
+		return result, nil
+	}
+
+	if f.Config.Flag != "" {
+		item := imap.FormatFlagsOp(imap.AddFlags, true)
+		err := c.UidStore(handled, item, []interface{}{f.Config.Flag}, nil)
+		if err != nil {
+			return result, fmt.Errorf("could not flag messages: %w", err)
+		}
+	}
+
+	if f.Config.MoveTo != "" {
+		if err := moveMessages(c, handled, f.Config.MoveTo); err != nil {
+			return result, fmt.Errorf(
+				"could not move messages to %s: %w",
+				f.Config.MoveTo,
+				err,
+			)
+		}
+	}
+
+	return result, nil
+}
+
+// moveMessages moves messages to another mailbox, falling back to copy,
+// delete and expunge on servers without the MOVE extension
+func moveMessages(c *client.Client, uids *imap.SeqSet, dest string) error {
+	supported, err := c.Support("MOVE")
+	if err != nil {
+		return err
+	}
+	if supported {
+		return c.UidMove(uids, dest)
+	}
+
+	if err := c.UidCopy(uids, dest); err != nil {
+		return err
+	}
+
+	item := imap.FormatFlagsOp(imap.AddFlags, true)
+	err = c.UidStore(uids, item, []interface{}{imap.DeletedFlag}, nil)
+	if err != nil {
+		return err
+	}
+
+	return c.Expunge(nil)
+}
+
+// connect dials the server and secures the connection
+func (f *IMAPFetcher) connect() (*client.Client, error) {
+	tlsConfig := f.Config.TLSConfig
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	if tlsConfig.ServerName == "" {
+		host, _, err := net.SplitHostPort(f.Config.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IMAP address %s: %w", f.Config.Addr, err)
+		}
+		tlsConfig = tlsConfig.Clone()
+		tlsConfig.ServerName = host
+	}
+
+	switch f.Config.Security {
+	case SecurityTLS, "":
+		c, err := client.DialTLS(f.Config.Addr, tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to %s: %w", f.Config.Addr, err)
+		}
+		return c, nil
+	case SecuritySTARTTLS:
+		c, err := client.Dial(f.Config.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to %s: %w", f.Config.Addr, err)
+		}
+		if err := c.StartTLS(tlsConfig); err != nil {
+			c.Logout()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+		return c, nil
+	default:
+		return nil, fmt.Errorf("unknown IMAP security mode %q", f.Config.Security)
+	}
+}
+
+// statePath returns the file that records processed UIDs
+func (f *IMAPFetcher) statePath() string {
+	return filepath.Join(f.Loader.ConfigDir, imapStateFile)
+}
+
+// findReportMessages inspects the body structure of the given messages and
+// returns the UIDs of those with a possible report attachment
+func findReportMessages(
+	c *client.Client,
+	uids *imap.SeqSet,
+) (map[uint32]bool, error) {
+	messages := make(chan *imap.Message, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(
+			uids,
+			[]imap.FetchItem{imap.FetchUid, imap.FetchBodyStructure},
+			messages,
+		)
+	}()
+
+	candidates := make(map[uint32]bool)
+	for msg := range messages {
+		if msg.BodyStructure != nil && hasReportPart(msg.BodyStructure) {
+			candidates[msg.Uid] = true
+		}
+	}
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("could not fetch body structures: %w", err)
+	}
+
+	return candidates, nil
+}
+
+// hasReportPart reports whether a body structure contains a part that may
+// hold a report
+func hasReportPart(bs *imap.BodyStructure) bool {
+	found := false
+	bs.Walk(func(_ []int, part *imap.BodyStructure) bool {
+		filename, _ := part.Filename()
+		att := mailbox.Attachment{
+			Filename: filename,
+			ContentType: strings.ToLower(
+				part.MIMEType + "/" + part.MIMESubType,
+			),
+		}
+		if att.IsReport() {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// fetchMessage downloads the raw content of a message
+func fetchMessage(c *client.Client, uid uint32) ([]byte, error) {
+	seqset := new(imap.SeqSet)
+	seqset.AddNum(uid)
+
+	section := &imap.BodySectionName{Peek: true}
+	messages := make(chan *imap.Message, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.UidFetch(
+			seqset,
+			[]imap.FetchItem{imap.FetchUid, section.FetchItem()},
+			messages,
+		)
+	}()
+
+	var raw []byte
+	var readErr error
+	for msg := range messages {
+		body := msg.GetBody(section)
+		if body == nil {
+			continue
+		}
+		raw, readErr = io.ReadAll(body)
+	}
+
+	if err := <-done; err != nil {
+		return nil, fmt.Errorf("could not fetch message %d: %w", uid, err)
+	}
+	if readErr != nil {
+		return nil, fmt.Errorf("could not read message %d: %w", uid, readErr)
+	}
+	if raw == nil {
+		return nil, fmt.Errorf("server returned no body for message %d", uid)
+	}
+
+	return raw, nil
+}
@@ -0,0 +1,112 @@
+package ingest
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// imapStateFile is the hidden file in the config directory that records
+// which messages have been processed
+const imapStateFile = ".imap-state.json"
+
+// imapState records processed UIDs per account and mailbox
+type imapState struct {
+	Mailboxes map[string]*mailboxState `json:"mailboxes"`
+}
+
+// mailboxState records the processed UIDs of one mailbox. UIDs are only
+// meaningful together with the mailbox's UIDVALIDITY.
+type mailboxState struct {
+	UIDValidity uint32   `json:"uid_validity"`
+	UIDs        []uint32 `json:"uids"`
+
+	set map[uint32]bool
+}
+
+// loadIMAPState reads the state file, returning an empty state if it does
+// not exist yet
+func loadIMAPState(path string) (*imapState, error) {
+	state := &imapState{Mailboxes: make(map[string]*mailboxState)}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read IMAP state %s: %w", path, err)
+	}
+
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("invalid IMAP state %s: %w", path, err)
+	}
+	if state.Mailboxes == nil {
+		state.Mailboxes = make(map[string]*mailboxState)
+	}
+
+	return state, nil
+}
+
+// mailbox returns the state of a mailbox, discarding remembered UIDs if
+// the server has renumbered the mailbox
+func (s *imapState) mailbox(key string, uidValidity uint32) *mailboxState {
+	mb, ok := s.Mailboxes[key]
+	if !ok || mb.UIDValidity != uidValidity {
+		mb = &mailboxState{UIDValidity: uidValidity}
+		s.Mailboxes[key] = mb
+	}
+
+	mb.set = make(map[uint32]bool, len(mb.UIDs))
+	for _, uid := range mb.UIDs {
+		mb.set[uid] = true
+	}
+
+	return mb
+}
+
+// save writes the state atomically
+func (s *imapState) save(path string) error {
+	for _, mb := range s.Mailboxes {
+		sort.Slice(mb.UIDs, func(i, j int) bool { return mb.UIDs[i] < mb.UIDs[j] })
+	}
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode IMAP state: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".imap-state-*")
+	if err != nil {
+		return fmt.Errorf("could not write IMAP state: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("could not write IMAP state: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("could not write IMAP state: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("could not write IMAP state: %w", err)
+	}
+
+	return nil
+}
+
+// has reports whether a UID has been processed
+func (mb *mailboxState) has(uid uint32) bool {
+	return mb.set[uid]
+}
+
+// add marks a UID as processed
+func (mb *mailboxState) add(uid uint32) {
+	if !mb.set[uid] {
+		mb.set[uid] = true
+		mb.UIDs = append(mb.UIDs, uid)
+	}
+}
@@ -0,0 +1,35 @@
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/huhndev/godmarc/mailbox"
+	"github.com/huhndev/godmarc/storage"
+)
+
+// errMalformedMessage is returned by storeMessage for messages that cannot be
+// parsed as MIME
+var errMalformedMessage = errors.New("malformed message")
+
+// storeMessage saves a raw message as an .eml file in the report store if
+// it carries at least one report attachment. Keeping the whole message
+// rather than the bare attachments preserves its Date, From and
+// Message-ID for the loader. It returns the saved file name, or an empty
+// string if the message holds no report.
+func storeMessage(
+	loader *storage.ReportLoader,
+	name string,
+	raw []byte,
+) (string, error) {
+	msg, err := mailbox.ParseMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", errMalformedMessage, err)
+	}
+	if !msg.HasReports() {
+		return "", nil
+	}
+
+	return loader.SaveReport(name, raw)
+}
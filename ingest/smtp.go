@@ -0,0 +1,209 @@
+package ingest
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/emersion/go-smtp"
+	"github.com/huhndev/godmarc/storage"
+)
+
+// DefaultMaxMessageBytes is the default size limit for received messages
+const DefaultMaxMessageBytes = 25 * 1024 * 1024
+
+// SMTPConfig configures the built-in SMTP receiver
+type SMTPConfig struct {
+	// Addr is the address to listen on, e.g. ":25"
+	Addr string
+	// Domain is the host name announced in the greeting
+	Domain string
+	// MaxMessageBytes limits the size of a message, DefaultMaxMessageBytes
+	// if zero
+	MaxMessageBytes int64
+	// MaxRecipients limits the recipients per message, unlimited if zero
+	MaxRecipients int
+	// AllowedRecipients lists accepted recipient addresses. An entry of the
+	// form "@example.com" accepts every address of that domain. All
+	// recipients are accepted if the list is empty.
+	AllowedRecipients []string
+	// TLSConfig enables STARTTLS if set
+	TLSConfig *tls.Config
+	// Log receives a line per accepted or rejected message if set
+	Log *log.Logger
+}
+
+// SMTPReceiver accepts rua mail and stores messages that carry reports
+type SMTPReceiver struct {
+	config SMTPConfig
+	loader *storage.ReportLoader
+	server *smtp.Server
+	count  atomic.Uint64
+}
+
+// NewSMTPReceiver creates an SMTPReceiver that saves into loader's
+// directory
+func NewSMTPReceiver(
+	config SMTPConfig,
+	loader *storage.ReportLoader,
+) *SMTPReceiver {
+	if config.MaxMessageBytes == 0 {
+		config.MaxMessageBytes = DefaultMaxMessageBytes
+	}
+
+	r := &SMTPReceiver{config: config, loader: loader}
+
+	s := smtp.NewServer(r)
+	s.Addr = config.Addr
+	s.Domain = config.Domain
+	s.MaxMessageBytes = config.MaxMessageBytes
+	s.MaxRecipients = config.MaxRecipients
+	s.TLSConfig = config.TLSConfig
+	s.ReadTimeout = 5 * time.Minute
+	s.WriteTimeout = 5 * time.Minute
+	r.server = s
+
+	return r
+}
+
+// ListenAndServe listens on the configured address and handles incoming
+// connections until Close is called
+func (r *SMTPReceiver) ListenAndServe() error {
+	return r.server.ListenAndServe()
+}
+
+// Serve handles incoming connections on l until Close is called
+func (r *SMTPReceiver) Serve(l net.Listener) error {
+	return r.server.Serve(l)
+}
+
+// Close stops the receiver
+func (r *SMTPReceiver) Close() error {
+	return r.server.Close()
+}
+
+// NewSession implements smtp.Backend
+func (r *SMTPReceiver) NewSession(c *smtp.Conn) (smtp.Session, error) {
+	return &smtpSession{receiver: r, remote: c.Conn().RemoteAddr()}, nil
+}
+
+// recipientAllowed checks an address against the allow-list
+func (r *SMTPReceiver) recipientAllowed(addr string) bool {
+	if len(r.config.AllowedRecipients) == 0 {
+		return true
+	}
+
+	addr = strings.ToLower(addr)
+	for _, allowed := range r.config.AllowedRecipients {
+		allowed = strings.ToLower(allowed)
+		if strings.HasPrefix(allowed, "@") {
+			if strings.HasSuffix(addr, allowed) {
+				return true
+			}
+		} else if addr == allowed {
+			return true
+		}
+	}
+
+	return false
+}
+
+// logf logs a message if logging is enabled
+func (r *SMTPReceiver) logf(format string, args ...any) {
+	if r.config.Log != nil {
+		r.config.Log.Printf(format, args...)
+	}
+}
+
+// smtpSession is the state of one SMTP transaction
+type smtpSession struct {
+	receiver *SMTPReceiver
+	remote   net.Addr
+	from     string
+	to       []string
+}
+
+// Reset implements smtp.Session
+func (s *smtpSession) Reset() {
+	s.from = ""
+	s.to = nil
+}
+
+// Logout implements smtp.Session
+func (s *smtpSession) Logout() error {
+	return nil
+}
+
+// Mail implements smtp.Session
+func (s *smtpSession) Mail(from string, _ *smtp.MailOptions) error {
+	s.from = from
+	return nil
+}
+
+// Rcpt implements smtp.Session
+func (s *smtpSession) Rcpt(to string, _ *smtp.RcptOptions) error {
+	if !s.receiver.recipientAllowed(to) {
+		s.receiver.logf("rejected recipient %s from %s", to, s.remote)
+		return &smtp.SMTPError{
+			Code:         550,
+			EnhancedCode: smtp.EnhancedCode{5, 1, 1},
+			Message:      "Recipient not accepted",
+		}
+	}
+
+	s.to = append(s.to, to)
+	return nil
+}
+
+// Data implements smtp.Session
+func (s *smtpSession) Data(r io.Reader) error {
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		if errors.Is(err, smtp.ErrDataTooLarge) {
+			s.receiver.logf("rejected oversized message from %s", s.remote)
+		}
+		return err
+	}
+
+	name := fmt.Sprintf(
+		"smtp-%d-%d.eml",
+		time.Now().Unix(),
+		s.receiver.count.Add(1),
+	)
+
+	saved, err := storeMessage(s.receiver.loader, name, raw)
+	if errors.Is(err, errMalformedMessage) {
+		s.receiver.logf("rejected message from %s: %v", s.from, err)
+		return &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      "Message could not be parsed",
+		}
+	}
+	if err != nil {
+		s.receiver.logf("could not store message from %s: %v", s.remote, err)
+		return &smtp.SMTPError{
+			Code:         451,
+			EnhancedCode: smtp.EnhancedCode{4, 3, 0},
+			Message:      "Could not store message",
+		}
+	}
+
+	if saved == "" {
+		s.receiver.logf("rejected message without report from %s", s.from)
+		return &smtp.SMTPError{
+			Code:         554,
+			EnhancedCode: smtp.EnhancedCode{5, 6, 0},
+			Message:      "No DMARC report found in message",
+		}
+	}
+
+	s.receiver.logf("stored report from %s as %s", s.from, saved)
+	return nil
+}
@@ -0,0 +1,274 @@
+// Package mailbox extracts report attachments from raw email messages,
+// mbox files and Maildir directories
+package mailbox
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"path"
+	"strings"
+	"time"
+)
+
+// maxPartSize limits the decoded size of a single MIME part
+const maxPartSize = 50 * 1024 * 1024
+
+// maxNestingDepth limits how deeply multipart bodies may be nested
+const maxNestingDepth = 10
+
+// Message is an email message reduced to its provenance headers and
+// attachments
+type Message struct {
+	Date        time.Time
+	From        string
+	MessageID   string
+	Attachments []Attachment
+}
+
+// Attachment is a decoded, non-text MIME part of a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// ReportFormat classifies an attachment as ".xml", ".gz" or ".zip" for
+// aggregate reports, or ".json" or ".json.gz" for TLS reports, by its file
+// name, falling back to its content type. It returns an empty string for
+// attachments that cannot contain a report.
+func (a Attachment) ReportFormat() string {
+	if format := FormatFromFilename(a.Filename); format != "" {
+		return format
+	}
+
+	switch a.ContentType {
+	case "application/zip", "application/x-zip-compressed":
+		return ".zip"
+	case "application/gzip", "application/x-gzip":
+		return ".gz"
+	case "text/xml", "application/xml":
+		return ".xml"
+	case "application/tlsrpt+json":
+		return ".json"
+	case "application/tlsrpt+gzip":
+		return ".json.gz"
+	}
+
+	return ""
+}
+
+// FormatFromFilename classifies a report file by its extension like
+// Attachment.ReportFormat does. It returns an empty string for other files.
+func FormatFromFilename(name string) string {
+	name = strings.ToLower(name)
+	if strings.HasSuffix(name, ".json.gz") {
+		return ".json.gz"
+	}
+
+	switch ext := path.Ext(name); ext {
+	case ".xml", ".gz", ".zip", ".json":
+		return ext
+	}
+
+	return ""
+}
+
+// IsReport reports whether the attachment may contain an aggregate report
+// or is the machine-readable part of a failure report
+func (a Attachment) IsReport() bool {
+	return a.ReportFormat() != "" || a.ContentType == "message/feedback-report"
+}
+
+// HasReports reports whether any attachment of the message may contain a
+// report
+func (m Message) HasReports() bool {
+	for _, att := range m.Attachments {
+		if att.IsReport() {
+			return true
+		}
+	}
+	return false
+}
+
+// FeedbackReport returns the machine-readable part of an RFC 5965 feedback
+// report (such as an RFC 6591 failure report), if the message has one
+func (m Message) FeedbackReport() (Attachment, bool) {
+	for _, att := range m.Attachments {
+		if att.ContentType == "message/feedback-report" {
+			return att, true
+		}
+	}
+	return Attachment{}, false
+}
+
+// ParseMessage reads a raw RFC 5322 message and decodes its attachments
+func ParseMessage(r io.Reader) (Message, error) {
+	var msg Message
+
+	m, err := mail.ReadMessage(r)
+	if err != nil {
+		return msg, fmt.Errorf("invalid message: %w", err)
+	}
+
+	if date, err := m.Header.Date(); err == nil {
+		msg.Date = date
+	}
+
+	msg.From = m.Header.Get("From")
+	if addr, err := mail.ParseAddress(msg.From); err == nil {
+		msg.From = addr.Address
+	}
+
+	msg.MessageID = strings.TrimSpace(m.Header.Get("Message-ID"))
+
+	if err := collectAttachments(&msg, m.Header, m.Body, 0); err != nil {
+		return msg, err
+	}
+
+	return msg, nil
+}
+
+// partHeader is the subset of header access shared by mail.Header and
+// textproto.MIMEHeader
+type partHeader interface {
+	Get(key string) string
+}
+
+// collectAttachments walks a (possibly multipart) body and appends every
+// attachment it finds to msg
+func collectAttachments(
+	msg *Message,
+	header partHeader,
+	body io.Reader,
+	depth int,
+) error {
+	if depth > maxNestingDepth {
+		return fmt.Errorf("multipart nesting deeper than %d", maxNestingDepth)
+	}
+
+	contentType := header.Get("Content-Type")
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		// RFC 2045 defaults to plain text when the type is missing or broken
+		mediaType = "text/plain"
+		params = map[string]string{}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		boundary := params["boundary"]
+		if boundary == "" {
+			return fmt.Errorf("multipart body without boundary")
+		}
+
+		mr := multipart.NewReader(body, boundary)
+		for {
+			part, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("invalid multipart body: %w", err)
+			}
+
+			err = collectAttachments(msg, part.Header, part, depth+1)
+			part.Close()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	filename := attachmentFilename(header, params)
+	if filename == "" &&
+		(mediaType == "text/plain" || mediaType == "text/html") {
+		// Message text, not an attachment
+		return nil
+	}
+
+	data, err := decodeBody(header.Get("Content-Transfer-Encoding"), body)
+	if err != nil {
+		return fmt.Errorf("could not decode part %q: %w", filename, err)
+	}
+
+	msg.Attachments = append(msg.Attachments, Attachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		Data:        data,
+	})
+
+	return nil
+}
+
+// attachmentFilename returns the file name of a part from its
+// Content-Disposition or, failing that, the Content-Type name parameter
+func attachmentFilename(header partHeader, typeParams map[string]string) string {
+	var filename string
+
+	_, dispParams, err := mime.ParseMediaType(header.Get("Content-Disposition"))
+	if err == nil {
+		filename = dispParams["filename"]
+	}
+	if filename == "" {
+		filename = typeParams["name"]
+	}
+
+	// Decode RFC 2047 encoded words, which some mailers use for file names
+	dec := new(mime.WordDecoder)
+	if decoded, err := dec.DecodeHeader(filename); err == nil {
+		filename = decoded
+	}
+
+	return strings.TrimSpace(filename)
+}
+
+// decodeBody decodes a part body according to its transfer encoding
+func decodeBody(encoding string, body io.Reader) ([]byte, error) {
+	var r io.Reader
+
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, &base64Filter{r: body})
+	case "quoted-printable":
+		r = quotedprintable.NewReader(body)
+	default:
+		r = body
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxPartSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > maxPartSize {
+		return nil, fmt.Errorf("part exceeds %d bytes", maxPartSize)
+	}
+
+	return data, nil
+}
+
+// base64Filter drops the whitespace mailers insert into base64 bodies,
+// beyond the line breaks the base64 decoder already skips
+type base64Filter struct {
+	r io.Reader
+}
+
+func (f *base64Filter) Read(p []byte) (int, error) {
+	for {
+		n, err := f.r.Read(p)
+		kept := 0
+		for _, b := range p[:n] {
+			if b != ' ' && b != '\t' {
+				p[kept] = b
+				kept++
+			}
+		}
+		if kept > 0 || err != nil {
+			return kept, err
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package mailbox
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IsMaildir reports whether dir looks like a Maildir, i.e. it has both a
+// cur and a new subdirectory
+func IsMaildir(dir string) bool {
+	for _, sub := range []string{"cur", "new"} {
+		info, err := os.Stat(filepath.Join(dir, sub))
+		if err != nil || !info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// MaildirMessages returns the paths of all delivered messages in a Maildir,
+// sorted by name. Messages still being delivered to tmp are ignored.
+func MaildirMessages(dir string) ([]string, error) {
+	var paths []string
+
+	for _, sub := range []string{"cur", "new"} {
+		subdir := filepath.Join(dir, sub)
+		entries, err := os.ReadDir(subdir)
+		if err != nil {
+			return nil, fmt.Errorf("could not read %s: %w", subdir, err)
+		}
+
+		for _, entry := range entries {
+			if !entry.Type().IsRegular() ||
+				strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+			paths = append(paths, filepath.Join(subdir, entry.Name()))
+		}
+	}
+
+	sort.Strings(paths)
+
+	return paths, nil
+}
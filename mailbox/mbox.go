@@ -0,0 +1,60 @@
+package mailbox
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// SplitMbox splits an mbox file into its raw messages. Lines quoted as
+// ">From " by mboxrd writers are unquoted. All messages are held in memory,
+// so the caller bounds the size of r.
+func SplitMbox(r io.Reader) ([][]byte, error) {
+	br := bufio.NewReader(r)
+
+	var messages [][]byte
+	var current *bytes.Buffer
+	prevBlank := true
+
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			switch {
+			case prevBlank && bytes.HasPrefix(line, []byte("From ")):
+				if current != nil {
+					messages = append(messages, current.Bytes())
+				}
+				current = new(bytes.Buffer)
+			case current == nil:
+				return nil, fmt.Errorf("not an mbox file: missing From_ line")
+			default:
+				if isQuotedFrom(line) {
+					line = line[1:]
+				}
+				current.Write(line)
+			}
+
+			prevBlank = len(bytes.TrimRight(line, "\r\n")) == 0
+		}
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not read mbox: %w", err)
+		}
+	}
+
+	if current != nil {
+		messages = append(messages, current.Bytes())
+	}
+
+	return messages, nil
+}
+
+// isQuotedFrom reports whether a line is a ">From " line escaped by mboxrd
+func isQuotedFrom(line []byte) bool {
+	trimmed := bytes.TrimLeft(line, ">")
+	return len(trimmed) < len(line) && bytes.HasPrefix(trimmed, []byte("From "))
+}
@@ -1,12 +1,18 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"io/fs"
 	"os"
 	"runtime/debug"
-	"strings"
+	"sort"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/huhndev/godmarc/parser"
+	"github.com/huhndev/godmarc/provider"
+	"github.com/huhndev/godmarc/publicsuffix"
 	"github.com/huhndev/godmarc/storage"
 	"github.com/huhndev/godmarc/ui"
 )
@@ -21,8 +27,80 @@ func main() {
 		}
 	}()
 
+	lenient := flag.Bool(
+		"lenient",
+		false,
+		"keep the readable records of partially malformed reports",
+	)
+	workers := flag.Int(
+		"workers",
+		0,
+		"number of files to load in parallel (default one per CPU)",
+	)
+	database := flag.String(
+		"db",
+		"",
+		"read reports from this database file instead of ~/.godmarc",
+	)
+	suffixList := flag.String(
+		"psl",
+		"",
+		"read the public suffix list from this file (default built in)",
+	)
+	providers := flag.String(
+		"providers",
+		"",
+		"extra provider rules file (default ~/.config/godmarc/providers.json)",
+	)
+	resolve := flag.Bool(
+		"resolve",
+		false,
+		"look up reverse DNS names of sources to classify providers",
+	)
+	buildQuery := queryFlags(flag.CommandLine)
+	flag.Parse()
+
+	// Organizational domains are looked up in a newer list if given
+	if *suffixList != "" {
+		list, err := publicsuffix.LoadFile(*suffixList)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		publicsuffix.SetDefault(list)
+	}
+
+	// Known email services are classified with the built-in rules and the
+	// user's own
+	if err := provider.LoadDefault(*providers); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Subcommands feed or query the stored reports instead of starting the
+	// TUI
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(args[0], args[1:]); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	query, err := buildQuery()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
 	// Initialize application model with better error handling
-	m, err := ui.NewModel()
+	m, err := ui.NewModel(ui.Config{
+		Lenient:    *lenient,
+		Workers:    *workers,
+		Database:   *database,
+		ResolvePTR: *resolve,
+		Query:      query,
+	})
 	if err != nil {
 		handleStartupError(err)
 		os.Exit(1)
@@ -30,16 +108,46 @@ func main() {
 
 	// Start bubbletea program with alt screen and mouse support
 	p := tea.NewProgram(m, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	final, err := p.Run()
+	m.Close()
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error running program: %v\n", err)
 		os.Exit(1)
 	}
+
+	// Loading runs inside the program, so its errors surface on exit
+	if m, ok := final.(ui.Model); ok && m.Err() != nil {
+		handleStartupError(m.Err())
+		os.Exit(1)
+	}
+}
+
+// runCommand dispatches a subcommand by name
+func runCommand(name string, args []string) error {
+	switch name {
+	case "fetch-imap":
+		return runFetchIMAP(args)
+	case "serve-smtp":
+		return runServeSMTP(args)
+	case "serve-http":
+		return runServeHTTP(args)
+	case "import-db":
+		return runImportDB(args)
+	case "list":
+		return runList(args)
+	case "query":
+		return runQuery(args)
+	case "delete":
+		return runDelete(args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
 }
 
 // handleStartupError provides user-friendly error messages for common startup issues
 func handleStartupError(err error) {
 	// Check for no reports found error
-	if strings.Contains(err.Error(), storage.ErrNoReports.Error()) {
+	if errors.Is(err, storage.ErrNoReports) {
 		fmt.Println("No DMARC reports found!")
 		fmt.Println("To use godmarc:")
 		fmt.Println("1. Place your DMARC XML reports in ~/.godmarc")
@@ -47,8 +155,15 @@ func handleStartupError(err error) {
 		return
 	}
 
+	// Report files that exist but could not be loaded, grouped by cause
+	var loadErr *storage.LoadError
+	if errors.As(err, &loadErr) {
+		printLoadErrors(loadErr)
+		return
+	}
+
 	// Handle permission issues
-	if os.IsPermission(err) {
+	if errors.Is(err, fs.ErrPermission) {
 		fmt.Println(
 			"Permission denied when accessing the configuration directory.",
 		)
@@ -57,7 +172,7 @@ func handleStartupError(err error) {
 	}
 
 	// Handle home directory issues
-	if strings.Contains(err.Error(), "home directory") {
+	if errors.Is(err, storage.ErrNoHomeDir) {
 		fmt.Println("Could not determine your home directory.")
 		fmt.Println("godmarc stores configuration in ~/.godmarc")
 		return
@@ -66,3 +181,26 @@ func handleStartupError(err error) {
 	// Generic fallback
 	fmt.Printf("Error initializing application: %v\n", err)
 }
+
+// printLoadErrors lists the files that could not be loaded, grouped by the
+// kind of problem
+func printLoadErrors(loadErr *storage.LoadError) {
+	groups := make(map[string][]error)
+	var categories []string
+	for _, err := range loadErr.Errs {
+		category := parser.ErrorCategory(err)
+		if _, ok := groups[category]; !ok {
+			categories = append(categories, category)
+		}
+		groups[category] = append(groups[category], err)
+	}
+	sort.Strings(categories)
+
+	fmt.Println("None of the files in ~/.godmarc could be loaded:")
+	for _, category := range categories {
+		fmt.Printf("\n%s (%d):\n", category, len(groups[category]))
+		for _, err := range groups[category] {
+			fmt.Printf("  %v\n", err)
+		}
+	}
+}
@@ -4,17 +4,72 @@ import (
 	"strings"
 )
 
-// AggregatedReport represents an aggregated view of multiple DMARC reports
+// AggregatedReport represents an aggregated view of multiple DMARC reports.
+// Results are weighted by the number of messages each record stands for.
 type AggregatedReport struct {
 	TotalReports  int
 	TotalRecords  int
+	TotalMessages int
 	DateRange     DateRange
+	// Domains counts the reports per organizational domain of their
+	// policy domain
 	Domains       map[string]int
-	Sources       map[string]int
-	Dispositions  map[string]int
-	DKIMResults   map[string]int
-	SPFResults    map[string]int
+	Sources       map[string]Tally
+	Dispositions  map[string]Tally
+	DKIMResults   map[string]Tally
+	SPFResults    map[string]Tally
 	FailedRecords []FailedRecord
+	// Compliant counts the messages that passed DMARC, i.e. DKIM or SPF
+	// passed in the evaluated policy
+	Compliant Tally
+	// Duplicates is the number of reports left out as duplicates
+	Duplicates int
+	// Alignments counts the messages per AlignmentClass, keyed by its
+	// description
+	Alignments map[string]Tally
+	// Providers holds the known email service of each source IP address
+	// that belongs to one by its network or reverse DNS names, see
+	// SourceProvider
+	Providers map[string]string
+	// PolicyDomains counts the reports per policy domain, keyed by their
+	// organizational domain as in Domains
+	PolicyDomains map[string]map[string]int
+}
+
+// Tally counts messages and the records they were reported in
+type Tally struct {
+	Messages int
+	Records  int
+}
+
+// add counts a record of count messages
+func (t *Tally) add(count int) {
+	t.Messages += count
+	t.Records++
+}
+
+// Share returns the messages of t as a fraction of all messages, from 0
+// to 1. It is 0 if there are no messages.
+func (a AggregatedReport) Share(t Tally) float64 {
+	if a.TotalMessages == 0 {
+		return 0
+	}
+	return float64(t.Messages) / float64(a.TotalMessages)
+}
+
+// ComplianceRate returns the fraction of messages that passed DMARC
+func (a AggregatedReport) ComplianceRate() float64 {
+	return a.Share(a.Compliant)
+}
+
+// DKIMPassRate returns the fraction of messages that passed DKIM
+func (a AggregatedReport) DKIMPassRate() float64 {
+	return a.Share(a.DKIMResults["pass"])
+}
+
+// SPFPassRate returns the fraction of messages that passed SPF
+func (a AggregatedReport) SPFPassRate() float64 {
+	return a.Share(a.SPFResults["pass"])
 }
 
 // FailedRecord represents a record that failed DKIM or SPF validation
@@ -23,45 +78,74 @@ type FailedRecord struct {
 	Domain   string
 	Count    int
 	Reason   string
+	// Alignment is the alignment computed from the raw auth results
+	Alignment AlignmentClass
+	// Provider is the known email service the record was sent from
+	Provider string
 }
 
-// AggregateReports combines multiple DMARC reports into a single aggregated view
+// AggregateReports combines multiple DMARC reports into a single aggregated view.
+// Reports marked as duplicates are not counted.
 func AggregateReports(reports []DMARCReport) AggregatedReport {
 	aggr := AggregatedReport{
-		TotalReports: len(reports),
-		TotalRecords: 0,
-		Domains:      make(map[string]int),
-		Sources:      make(map[string]int),
-		Dispositions: make(map[string]int),
-		DKIMResults:  make(map[string]int),
-		SPFResults:   make(map[string]int),
-	}
-
-	// Initialize with min/max values for date range
-	if len(reports) > 0 {
-		aggr.DateRange.Begin = reports[0].ReportMetadata.DateRange.Begin
-		aggr.DateRange.End = reports[0].ReportMetadata.DateRange.End
+		TotalRecords:  0,
+		Domains:       make(map[string]int),
+		Sources:       make(map[string]Tally),
+		Dispositions:  make(map[string]Tally),
+		DKIMResults:   make(map[string]Tally),
+		SPFResults:    make(map[string]Tally),
+		Alignments:    make(map[string]Tally),
+		Providers:     make(map[string]string),
+		PolicyDomains: make(map[string]map[string]int),
 	}
 
 	for _, report := range reports {
-		// Update date range
-		if report.ReportMetadata.DateRange.Begin.Before(aggr.DateRange.Begin) {
-			aggr.DateRange.Begin = report.ReportMetadata.DateRange.Begin
+		if report.Duplicate {
+			aggr.Duplicates++
+			continue
+		}
+		aggr.TotalReports++
+
+		// Update date range, leaving out leniently parsed reports whose
+		// date range could not be read
+		dateRange := report.ReportMetadata.DateRange
+		if !dateRange.Begin.IsZero() && (aggr.DateRange.Begin.IsZero() ||
+			dateRange.Begin.Before(aggr.DateRange.Begin)) {
+			aggr.DateRange.Begin = dateRange.Begin
 		}
-		if report.ReportMetadata.DateRange.End.After(aggr.DateRange.End) {
-			aggr.DateRange.End = report.ReportMetadata.DateRange.End
+		if dateRange.End.After(aggr.DateRange.End) {
+			aggr.DateRange.End = dateRange.End
 		}
 
-		// Add domain
-		aggr.Domains[report.PolicyPublished.Domain]++
+		// Add domain, grouped by its organizational domain
+		domain := normalizeDomain(report.PolicyPublished.Domain)
+		orgDomain := OrganizationalDomain(domain)
+		if orgDomain == "" {
+			orgDomain = domain
+		}
+		aggr.Domains[orgDomain]++
+		if aggr.PolicyDomains[orgDomain] == nil {
+			aggr.PolicyDomains[orgDomain] = make(map[string]int)
+		}
+		aggr.PolicyDomains[orgDomain][domain]++
 
 		// Process records
 		for _, record := range report.Records {
+			evaluated := record.Row.PolicyEvaluated
+			count := max(record.Row.Count, 0)
+			alignment := record.Alignment(report.PolicyPublished).Class()
+			provider := record.Provider()
+
 			aggr.TotalRecords++
-			aggr.Sources[record.Row.SourceIP]++
-			aggr.Dispositions[record.Row.PolicyEvaluated.Disposition]++
-			aggr.DKIMResults[record.Row.PolicyEvaluated.DKIM]++
-			aggr.SPFResults[record.Row.PolicyEvaluated.SPF]++
+			aggr.TotalMessages += count
+			addTally(aggr.Sources, record.Row.SourceIP, count)
+			addTally(aggr.Dispositions, evaluated.Disposition, count)
+			addTally(aggr.DKIMResults, evaluated.DKIM, count)
+			addTally(aggr.SPFResults, evaluated.SPF, count)
+			addTally(aggr.Alignments, alignment.String(), count)
+			if evaluated.DKIM == "pass" || evaluated.SPF == "pass" {
+				aggr.Compliant.add(count)
+			}
 
 			// Track failed authentications
 			if record.Row.PolicyEvaluated.DKIM != "pass" ||
@@ -75,14 +159,31 @@ func AggregateReports(reports []DMARCReport) AggregatedReport {
 				}
 
 				aggr.FailedRecords = append(aggr.FailedRecords, FailedRecord{
-					SourceIP: record.Row.SourceIP,
-					Domain:   record.Identifiers.HeaderFrom,
-					Count:    record.Row.Count,
-					Reason:   strings.TrimSpace(reason),
+					SourceIP:  record.Row.SourceIP,
+					Domain:    record.Identifiers.HeaderFrom,
+					Count:     record.Row.Count,
+					Reason:    strings.TrimSpace(reason),
+					Alignment: alignment,
+					Provider:  provider,
 				})
 			}
 		}
 	}
 
+	// Sources are not labelled by DKIM domains: those only tell about the
+	// mail of one record, not about every record of the source
+	for ip := range aggr.Sources {
+		if name := SourceProvider(ip); name != "" {
+			aggr.Providers[ip] = name
+		}
+	}
+
 	return aggr
 }
+
+// addTally counts a record of count messages under key
+func addTally(tallies map[string]Tally, key string, count int) {
+	t := tallies[key]
+	t.add(count)
+	tallies[key] = t
+}
@@ -0,0 +1,190 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/huhndev/godmarc/publicsuffix"
+)
+
+// Identifier alignment modes of the adkim and aspf policy tags
+const (
+	AlignmentRelaxed = "r"
+	AlignmentStrict  = "s"
+)
+
+// OrganizationalDomain returns the organizational domain of a domain,
+// looked up in the Public Suffix List
+func OrganizationalDomain(domain string) string {
+	return publicsuffix.OrganizationalDomain(domain)
+}
+
+// HasOrganizationalDomain reports whether a domain is a name registered
+// under a public suffix of the Public Suffix List, so that its
+// organizational domain is meaningful
+func HasOrganizationalDomain(domain string) bool {
+	return publicsuffix.IsRegistered(domain)
+}
+
+// IsAligned reports whether an authenticated domain is aligned with the
+// header From domain under the alignment mode, relaxed unless it is
+// AlignmentStrict
+func IsAligned(domain, headerFrom, mode string) bool {
+	domain = normalizeDomain(domain)
+	headerFrom = normalizeDomain(headerFrom)
+	if domain == "" || headerFrom == "" {
+		return false
+	}
+	if strings.EqualFold(strings.TrimSpace(mode), AlignmentStrict) {
+		return domain == headerFrom
+	}
+	return OrganizationalDomain(domain) == OrganizationalDomain(headerFrom)
+}
+
+// PolicyFor returns the policy that applies to mail with a header From
+// domain: the subdomain policy (sp) if the domain is a subdomain, else p.
+// The non-existent subdomain policy (np) of DMARCbis is not applied, since
+// a report does not say whether a domain exists; see IsSubdomain.
+func (p PolicyPublished) PolicyFor(headerFrom string) string {
+	if p.SP != "" && p.IsSubdomain(headerFrom) {
+		return p.SP
+	}
+	return p.P
+}
+
+// IsSubdomain reports whether a header From domain is below the
+// organizational domain the policy was published for, so that the
+// subdomain policies sp and np apply to it
+func (p PolicyPublished) IsSubdomain(headerFrom string) bool {
+	from := normalizeDomain(headerFrom)
+	domain := normalizeDomain(p.Domain)
+	return from != "" && from != domain &&
+		OrganizationalDomain(from) == domain
+}
+
+// normalizeDomain lowercases a domain and strips surrounding space and a
+// trailing dot
+func normalizeDomain(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+// Alignment is the identifier alignment of a record, computed from its
+// raw authentication results rather than taken from the reporter
+type Alignment struct {
+	// DKIMPass and SPFPass are set if any DKIM signature or MAIL FROM SPF
+	// check passed, aligned or not
+	DKIMPass bool
+	SPFPass  bool
+	// DKIMAligned and SPFAligned are set if a passing DKIM signature or
+	// SPF check is aligned with the header From domain
+	DKIMAligned bool
+	SPFAligned  bool
+	// HeaderFrom is false if the record has no header From domain to
+	// align with
+	HeaderFrom bool
+}
+
+// Alignment computes the identifier alignment of a record under the
+// adkim and aspf modes of the published policy
+func (r Record) Alignment(policy PolicyPublished) Alignment {
+	headerFrom := r.Identifiers.HeaderFrom
+	a := Alignment{HeaderFrom: normalizeDomain(headerFrom) != ""}
+
+	for _, dkim := range r.AuthResults.DKIM {
+		if !strings.EqualFold(dkim.Result, "pass") {
+			continue
+		}
+		a.DKIMPass = true
+		if IsAligned(dkim.Domain, headerFrom, policy.ADKIM) {
+			a.DKIMAligned = true
+		}
+	}
+	for _, spf := range r.AuthResults.SPF {
+		// Only the MAIL FROM identity counts for DMARC, not HELO
+		if !isMailFromScope(spf.Scope) ||
+			!strings.EqualFold(spf.Result, "pass") {
+			continue
+		}
+		a.SPFPass = true
+		if IsAligned(spf.Domain, headerFrom, policy.ASPF) {
+			a.SPFAligned = true
+		}
+	}
+
+	return a
+}
+
+// isMailFromScope reports whether an SPF result scope is the MAIL FROM
+// identity. Reports without a scope only give that result.
+func isMailFromScope(scope string) bool {
+	scope = strings.TrimSpace(scope)
+	return scope == "" || strings.EqualFold(scope, "mfrom")
+}
+
+// Passes reports whether DMARC passes, i.e. DKIM or SPF passed aligned
+func (a Alignment) Passes() bool {
+	return a.DKIMAligned || a.SPFAligned
+}
+
+// AlignmentClass classifies a record by its identifier alignment
+type AlignmentClass int
+
+// Alignment classes, from best to worst
+const (
+	AlignedBoth AlignmentClass = iota
+	AlignedDKIM
+	AlignedSPF
+	UnalignedBoth
+	UnalignedDKIM
+	UnalignedSPF
+	NoAuthPass
+	NoHeaderFrom
+)
+
+// String returns a description of the alignment class
+func (c AlignmentClass) String() string {
+	switch c {
+	case AlignedBoth:
+		return "DKIM and SPF aligned"
+	case AlignedDKIM:
+		return "DKIM aligned"
+	case AlignedSPF:
+		return "SPF aligned"
+	case UnalignedBoth:
+		return "DKIM and SPF pass but unaligned"
+	case UnalignedDKIM:
+		return "DKIM pass but unaligned"
+	case UnalignedSPF:
+		return "SPF pass only via an unaligned bounce domain"
+	case NoAuthPass:
+		return "no DKIM or SPF pass"
+	default:
+		return "no header From domain"
+	}
+}
+
+// Passes reports whether records of the class pass DMARC
+func (c AlignmentClass) Passes() bool {
+	return c <= AlignedSPF
+}
+
+// Class classifies the alignment
+func (a Alignment) Class() AlignmentClass {
+	switch {
+	case !a.HeaderFrom:
+		return NoHeaderFrom
+	case a.DKIMAligned && a.SPFAligned:
+		return AlignedBoth
+	case a.DKIMAligned:
+		return AlignedDKIM
+	case a.SPFAligned:
+		return AlignedSPF
+	case a.DKIMPass && a.SPFPass:
+		return UnalignedBoth
+	case a.DKIMPass:
+		return UnalignedDKIM
+	case a.SPFPass:
+		return UnalignedSPF
+	default:
+		return NoAuthPass
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -13,11 +14,81 @@ type DateRange struct {
 	End   time.Time
 }
 
+// SchemaVersion identifies the aggregate report format a report was parsed from
+type SchemaVersion string
+
+const (
+	// SchemaRFC7489 is the original aggregate report format of RFC 7489
+	SchemaRFC7489 SchemaVersion = "RFC 7489"
+	// SchemaDMARCbis is the revised aggregate report format of DMARCbis
+	SchemaDMARCbis SchemaVersion = "DMARCbis"
+)
+
+// DMARCbisNamespace is the XML namespace of DMARCbis aggregate reports
+const DMARCbisNamespace = "urn:ietf:params:xml:ns:dmarc-2.0"
+
 // DMARCReport represents a parsed DMARC report
 type DMARCReport struct {
+	Schema          SchemaVersion   `xml:"-"`
+	Version         string          `xml:"version"`
 	ReportMetadata  ReportMetadata  `xml:"report_metadata"`
 	PolicyPublished PolicyPublished `xml:"policy_published"`
 	Records         []Record        `xml:"record"`
+	Provenance      Provenance      `xml:"-"`
+	// Source identifies where the report is stored, e.g. the file it was
+	// loaded from. Several reports may share a source.
+	Source string `xml:"-"`
+	// Duplicate is set on a report that was received before, see
+	// MarkDuplicates
+	Duplicate bool `xml:"-"`
+
+	// Degraded is set when a report was parsed leniently and parts of it
+	// could not be read, so its data is incomplete
+	Degraded bool            `xml:"-"`
+	Problems []ReportProblem `xml:"-"`
+}
+
+// ReportProblem describes part of a report that could not be parsed
+type ReportProblem struct {
+	// Record is the position of the affected record in the document,
+	// starting at 1, or 0 for problems outside the records
+	Record int
+	// Field is the path of the affected element
+	Field string
+	Msg   string
+	// Skipped is set when the record could not be decoded and is missing
+	// from the report
+	Skipped bool
+}
+
+// AddProblem records a problem and marks the report as degraded
+func (r *DMARCReport) AddProblem(problem ReportProblem) {
+	r.Problems = append(r.Problems, problem)
+	r.Degraded = true
+}
+
+// SkippedRecords returns the number of records missing from a degraded
+// report
+func (r DMARCReport) SkippedRecords() int {
+	skipped := 0
+	for _, problem := range r.Problems {
+		if problem.Skipped {
+			skipped++
+		}
+	}
+	return skipped
+}
+
+// Provenance describes the email message a report was delivered in
+type Provenance struct {
+	Date      time.Time
+	From      string
+	MessageID string
+}
+
+// IsZero reports whether the report was loaded without a message
+func (p Provenance) IsZero() bool {
+	return p.Date.IsZero() && p.From == "" && p.MessageID == ""
 }
 
 // ReportMetadata contains metadata about the DMARC report
@@ -27,44 +98,103 @@ type ReportMetadata struct {
 	ExtraContact string    `xml:"extra_contact_info"`
 	ReportID     string    `xml:"report_id"`
 	DateRange    DateRange `xml:"date_range"`
+	Errors       []string  `xml:"error"`
+	Generator    string    `xml:"generator"`
 }
 
 // PolicyPublished contains the published DMARC policy
 type PolicyPublished struct {
-	Domain string `xml:"domain"`
-	ADKIM  string `xml:"adkim"`
-	ASPF   string `xml:"aspf"`
-	P      string `xml:"p"`
-	SP     string `xml:"sp"`
-	PCT    int    `xml:"pct"`
+	Domain          string `xml:"domain"`
+	ADKIM           string `xml:"adkim"`
+	ASPF            string `xml:"aspf"`
+	P               string `xml:"p"`
+	SP              string `xml:"sp"`
+	PCT             int    `xml:"pct"`
+	FO              string `xml:"fo"`
+	NP              string `xml:"np"`
+	PSD             string `xml:"psd"`
+	Testing         string `xml:"testing"`
+	DiscoveryMethod string `xml:"discovery_method"`
+}
+
+// HasDMARCbisFields reports whether any policy field introduced by DMARCbis
+// is present
+func (pp PolicyPublished) HasDMARCbisFields() bool {
+	return pp.NP != "" || pp.PSD != "" || pp.Testing != "" ||
+		pp.DiscoveryMethod != ""
 }
 
 // Record represents a single DMARC record
 type Record struct {
-	Row struct {
-		SourceIP        string `xml:"source_ip"`
-		Count           int    `xml:"count"`
-		PolicyEvaluated struct {
-			Disposition string `xml:"disposition"`
-			DKIM        string `xml:"dkim"`
-			SPF         string `xml:"spf"`
-		} `xml:"policy_evaluated"`
-	} `xml:"row"`
-	Identifiers struct {
-		HeaderFrom string `xml:"header_from"`
-	} `xml:"identifiers"`
-	AuthResults struct {
-		DKIM []struct {
-			Domain   string `xml:"domain"`
-			Result   string `xml:"result"`
-			Selector string `xml:"selector"`
-		} `xml:"dkim"`
-		SPF []struct {
-			Domain string `xml:"domain"`
-			Result string `xml:"result"`
-			Scope  string `xml:"scope"`
-		} `xml:"spf"`
-	} `xml:"auth_results"`
+	Row         Row         `xml:"row"`
+	Identifiers Identifiers `xml:"identifiers"`
+	AuthResults AuthResults `xml:"auth_results"`
+}
+
+// Row contains the source and the evaluated policy of a record
+type Row struct {
+	SourceIP        string          `xml:"source_ip"`
+	Count           int             `xml:"count"`
+	PolicyEvaluated PolicyEvaluated `xml:"policy_evaluated"`
+}
+
+// PolicyEvaluated contains the results of applying the DMARC policy
+type PolicyEvaluated struct {
+	Disposition string                 `xml:"disposition"`
+	DKIM        string                 `xml:"dkim"`
+	SPF         string                 `xml:"spf"`
+	Reasons     []PolicyOverrideReason `xml:"reason"`
+}
+
+// PolicyOverrideReason explains why the receiver applied a disposition
+// different from the published policy (e.g. forwarded or mailing_list)
+type PolicyOverrideReason struct {
+	Type    string `xml:"type"`
+	Comment string `xml:"comment"`
+}
+
+// Identifiers contains the identifiers of the messages in a record
+type Identifiers struct {
+	EnvelopeTo   string `xml:"envelope_to"`
+	EnvelopeFrom string `xml:"envelope_from"`
+	HeaderFrom   string `xml:"header_from"`
+}
+
+// AuthResults contains the raw DKIM and SPF authentication results
+type AuthResults struct {
+	DKIM []DKIMAuthResult `xml:"dkim"`
+	SPF  []SPFAuthResult  `xml:"spf"`
+}
+
+// DKIMAuthResult is the result of a single DKIM signature evaluation
+type DKIMAuthResult struct {
+	Domain      string `xml:"domain"`
+	Selector    string `xml:"selector"`
+	Result      string `xml:"result"`
+	HumanResult string `xml:"human_result"`
+}
+
+// SPFAuthResult is the result of an SPF evaluation
+type SPFAuthResult struct {
+	Domain      string `xml:"domain"`
+	Scope       string `xml:"scope"`
+	Result      string `xml:"result"`
+	HumanResult string `xml:"human_result"`
+}
+
+// HasOverride reports whether the receiver gave a reason for overriding
+// the published policy
+func (pe PolicyEvaluated) HasOverride() bool {
+	return len(pe.Reasons) > 0
+}
+
+// OverrideTypes returns the override reason types joined by commas
+func (pe PolicyEvaluated) OverrideTypes() string {
+	types := make([]string, 0, len(pe.Reasons))
+	for _, reason := range pe.Reasons {
+		types = append(types, reason.Type)
+	}
+	return strings.Join(types, ",")
 }
 
 // UnmarshalXML custom unmarshaler for date range
@@ -0,0 +1,51 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// DuplicateKey identifies a report across copies of it: by reporting
+// organization and report ID or, for reports without an ID, by a hash of
+// their content
+func (r DMARCReport) DuplicateKey() string {
+	if r.ReportMetadata.ReportID != "" {
+		return strings.ToLower(r.ReportMetadata.OrgName) + "\x00" +
+			r.ReportMetadata.ReportID
+	}
+
+	// Where and how the report was received does not matter
+	content := struct {
+		Metadata ReportMetadata
+		Policy   PolicyPublished
+		Records  []Record
+	}{r.ReportMetadata, r.PolicyPublished, r.Records}
+	data, err := json.Marshal(content)
+	if err != nil {
+		// JSON cannot hold every time, e.g. years after 9999
+		data = []byte(fmt.Sprintf("%v", content))
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// MarkDuplicates marks every report with the same DuplicateKey as an
+// earlier report in reports as a duplicate, and returns their number
+func MarkDuplicates(reports []DMARCReport) int {
+	seen := make(map[string]bool, len(reports))
+	duplicates := 0
+
+	for i := range reports {
+		key := reports[i].DuplicateKey()
+		reports[i].Duplicate = seen[key]
+		if reports[i].Duplicate {
+			duplicates++
+		}
+		seen[key] = true
+	}
+
+	return duplicates
+}
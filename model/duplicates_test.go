@@ -0,0 +1,44 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarkDuplicates(t *testing.T) {
+	report := func(org, id, ip string, begin time.Time) DMARCReport {
+		var r DMARCReport
+		r.ReportMetadata.OrgName = org
+		r.ReportMetadata.ReportID = id
+		r.ReportMetadata.DateRange.Begin = begin
+		r.Records = []Record{{Row: Row{SourceIP: ip, Count: 1}}}
+		return r
+	}
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// JSON cannot encode this time, so the content is hashed another way
+	future := time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	reports := []DMARCReport{
+		report("Google", "1", "192.0.2.1", day),
+		// Same reporter and ID, whatever the content
+		report("GOOGLE", "1", "192.0.2.2", day),
+		report("Google", "2", "192.0.2.1", day),
+		// Without an ID, only the same content is a duplicate
+		report("Google", "", "192.0.2.1", day),
+		report("Google", "", "192.0.2.2", day),
+		report("Google", "", "192.0.2.1", day),
+		report("Google", "", "192.0.2.1", future),
+		report("Google", "", "192.0.2.2", future),
+		report("Google", "", "192.0.2.2", future),
+	}
+	want := []bool{false, true, false, false, false, true, false, false, true}
+
+	if n := MarkDuplicates(reports); n != 3 {
+		t.Errorf("MarkDuplicates() = %d, want 3", n)
+	}
+	for i, report := range reports {
+		if report.Duplicate != want[i] {
+			t.Errorf("report %d: Duplicate = %v, want %v", i, report.Duplicate, want[i])
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package model
+
+import (
+	"net/netip"
+	"strings"
+	"time"
+)
+
+// ForensicReport represents a parsed RFC 6591 authentication failure report
+type ForensicReport struct {
+	FeedbackType     string
+	UserAgent        string
+	Version          string
+	AuthFailure      string
+	SourceIP         string
+	OriginalMailFrom string
+	OriginalRcptTo   []string
+	ArrivalDate      time.Time
+	ReportedDomain   string
+	DeliveryResult   string
+	DKIMDomain       string
+	DKIMIdentity     string
+	DKIMSelector     string
+	SPFDNS           string
+	IdentityAlign    string
+	AuthResults      []string
+
+	// Headers of the failed message as included (and usually redacted) by
+	// the reporter
+	OriginalHeaders string
+	Subject         string
+	HeaderFrom      string
+	MessageID       string
+
+	Provenance Provenance
+}
+
+// Date returns when the failed message arrived, falling back to the date
+// of the report message
+func (r ForensicReport) Date() time.Time {
+	if !r.ArrivalDate.IsZero() {
+		return r.ArrivalDate
+	}
+	return r.Provenance.Date
+}
+
+// LinkedRecord is an aggregate record together with the report it is from
+type LinkedRecord struct {
+	OrgName   string
+	ReportID  string
+	DateRange DateRange
+	Record    Record
+}
+
+// IndexRecordsBySourceIP groups the records of all aggregate reports by
+// source IP, so failure reports can be linked to the matching aggregate data
+func IndexRecordsBySourceIP(reports []DMARCReport) map[string][]LinkedRecord {
+	index := make(map[string][]LinkedRecord)
+
+	for _, report := range reports {
+		for _, record := range report.Records {
+			ip := NormalizeIP(record.Row.SourceIP)
+			index[ip] = append(index[ip], LinkedRecord{
+				OrgName:   report.ReportMetadata.OrgName,
+				ReportID:  report.ReportMetadata.ReportID,
+				DateRange: report.ReportMetadata.DateRange,
+				Record:    record,
+			})
+		}
+	}
+
+	return index
+}
+
+// NormalizeIP returns the canonical text form of an IP address, so that
+// differently written IPv6 addresses compare equal. Unparsable input is
+// returned trimmed but otherwise unchanged.
+func NormalizeIP(s string) string {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.Unmap().String()
+	}
+	return s
+}
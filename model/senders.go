@@ -0,0 +1,217 @@
+package model
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/huhndev/godmarc/provider"
+)
+
+// Sender groups the records of a sending service, identified by the
+// domains it authenticates as and the network it sends from rather than by
+// single IP addresses
+type Sender struct {
+	// DKIMDomain is the d= domain of the record's DKIM signature,
+	// preferring passing and aligned ones. It is empty if mail was not
+	// signed.
+	DKIMDomain string
+	// SPFDomain is the MAIL FROM domain SPF checked
+	SPFDomain string
+	// Network is the source network: the /24 of an IPv4 address or the
+	// /48 of an IPv6 address
+	Network string
+	Tally
+	// Compliant counts the messages that passed DMARC by the alignment
+	// computed from the auth results
+	Compliant int
+	// Alignments counts the messages per alignment class
+	Alignments map[AlignmentClass]int
+	// FirstSeen and LastSeen are the earliest begin and latest end of the
+	// date ranges of the reports the sender was seen in
+	FirstSeen time.Time
+	LastSeen  time.Time
+	// Reporters are the organizations that reported the sender, sorted
+	Reporters []string
+}
+
+// PassRate returns the fraction of the sender's messages that passed DMARC
+func (s Sender) PassRate() float64 {
+	if s.Messages == 0 {
+		return 0
+	}
+	return float64(s.Compliant) / float64(s.Messages)
+}
+
+// Alignment returns the alignment class of most of the sender's messages
+func (s Sender) Alignment() AlignmentClass {
+	best, bestMessages := NoHeaderFrom, -1
+	for class, messages := range s.Alignments {
+		if messages > bestMessages ||
+			(messages == bestMessages && class < best) {
+			best, bestMessages = class, messages
+		}
+	}
+	return best
+}
+
+// Provider returns the known email service a record was sent from, by its
+// source IP address and passing DKIM signatures, or "" if it is unknown
+func (r Record) Provider() string {
+	var domains []string
+	for _, dkim := range r.AuthResults.DKIM {
+		if strings.EqualFold(dkim.Result, "pass") {
+			domains = append(domains, dkim.Domain)
+		}
+	}
+	return provider.Classify(r.Row.SourceIP, domains...)
+}
+
+// SourceProvider returns the known email service an IP address belongs to
+// by its network or reverse DNS names alone, or "" if it is unknown.
+// Unlike Record.Provider, it does not depend on the mail of one record.
+func SourceProvider(ip string) string {
+	return provider.Classify(ip)
+}
+
+// senderKey identifies a sender
+type senderKey struct {
+	dkim, spf, network string
+}
+
+// Senders groups the records of reports by sender, most messages first.
+// Reports marked as duplicates are not counted.
+func Senders(reports []DMARCReport) []Sender {
+	senders := make(map[senderKey]*Sender)
+	reporters := make(map[senderKey]map[string]bool)
+
+	for _, report := range reports {
+		if report.Duplicate {
+			continue
+		}
+		dateRange := report.ReportMetadata.DateRange
+
+		for _, record := range report.Records {
+			key := senderKey{
+				dkim:    senderDKIMDomain(record, report.PolicyPublished),
+				spf:     senderSPFDomain(record),
+				network: sourceNetwork(record.Row.SourceIP),
+			}
+			sender, ok := senders[key]
+			if !ok {
+				sender = &Sender{
+					DKIMDomain: key.dkim,
+					SPFDomain:  key.spf,
+					Network:    key.network,
+					Alignments: make(map[AlignmentClass]int),
+				}
+				senders[key] = sender
+				reporters[key] = make(map[string]bool)
+			}
+
+			count := max(record.Row.Count, 0)
+			class := record.Alignment(report.PolicyPublished).Class()
+			sender.add(count)
+			sender.Alignments[class] += count
+			if class.Passes() {
+				sender.Compliant += count
+			}
+
+			// Leniently parsed reports may lack their date range
+			if !dateRange.Begin.IsZero() && (sender.FirstSeen.IsZero() ||
+				dateRange.Begin.Before(sender.FirstSeen)) {
+				sender.FirstSeen = dateRange.Begin
+			}
+			if dateRange.End.After(sender.LastSeen) {
+				sender.LastSeen = dateRange.End
+			}
+			if org := report.ReportMetadata.OrgName; org != "" {
+				reporters[key][org] = true
+			}
+		}
+	}
+
+	result := make([]Sender, 0, len(senders))
+	for key, sender := range senders {
+		for org := range reporters[key] {
+			sender.Reporters = append(sender.Reporters, org)
+		}
+		sort.Strings(sender.Reporters)
+		result = append(result, *sender)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.Messages != b.Messages {
+			return a.Messages > b.Messages
+		}
+		if a.DKIMDomain != b.DKIMDomain {
+			return a.DKIMDomain < b.DKIMDomain
+		}
+		if a.SPFDomain != b.SPFDomain {
+			return a.SPFDomain < b.SPFDomain
+		}
+		return a.Network < b.Network
+	})
+	return result
+}
+
+// senderDKIMDomain returns the DKIM domain a record is grouped by: the
+// first aligned passing signature, else the first passing one, else the
+// first one
+func senderDKIMDomain(record Record, policy PolicyPublished) string {
+	signatures := record.AuthResults.DKIM
+	if len(signatures) == 0 {
+		return ""
+	}
+
+	best := signatures[0]
+	bestRank := 0
+	for _, dkim := range signatures {
+		rank := 0
+		if strings.EqualFold(dkim.Result, "pass") {
+			rank = 1
+			if IsAligned(dkim.Domain, record.Identifiers.HeaderFrom, policy.ADKIM) {
+				rank = 2
+			}
+		}
+		if rank > bestRank {
+			best, bestRank = dkim, rank
+		}
+	}
+	return normalizeDomain(best.Domain)
+}
+
+// senderSPFDomain returns the SPF domain a record is grouped by: the MAIL
+// FROM domain, or the envelope From of the record if SPF was not reported
+func senderSPFDomain(record Record) string {
+	for _, spf := range record.AuthResults.SPF {
+		if isMailFromScope(spf.Scope) {
+			return normalizeDomain(spf.Domain)
+		}
+	}
+	if len(record.AuthResults.SPF) > 0 {
+		return normalizeDomain(record.AuthResults.SPF[0].Domain)
+	}
+	return normalizeDomain(record.Identifiers.EnvelopeFrom)
+}
+
+// sourceNetwork returns the network of a source IP address, or the
+// address as is if it cannot be parsed
+func sourceNetwork(ip string) string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return ip
+	}
+	addr = addr.Unmap()
+
+	bits := 48
+	if addr.Is4() {
+		bits = 24
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return ip
+	}
+	return prefix.String()
+}
@@ -0,0 +1,184 @@
+package model
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// TLSReport represents a parsed RFC 8460 SMTP TLS report
+type TLSReport struct {
+	OrganizationName string            `json:"organization-name"`
+	DateRange        TLSDateRange      `json:"date-range"`
+	ContactInfo      string            `json:"contact-info"`
+	ReportID         string            `json:"report-id"`
+	Policies         []TLSPolicyResult `json:"policies"`
+	Provenance       Provenance        `json:"-"`
+}
+
+// TLSDateRange is the period a TLS report covers
+type TLSDateRange struct {
+	Start time.Time `json:"start-datetime"`
+	End   time.Time `json:"end-datetime"`
+}
+
+// TLSPolicyResult holds the session results for one applied policy
+type TLSPolicyResult struct {
+	Policy         TLSPolicy          `json:"policy"`
+	Summary        TLSSummary         `json:"summary"`
+	FailureDetails []TLSFailureDetail `json:"failure-details"`
+}
+
+// TLSPolicy describes the MTA-STS or DANE policy that was applied
+type TLSPolicy struct {
+	PolicyType   string     `json:"policy-type"`
+	PolicyString []string   `json:"policy-string"`
+	PolicyDomain string     `json:"policy-domain"`
+	MXHost       StringList `json:"mx-host"`
+}
+
+// TLSSummary counts successful and failed TLS sessions
+type TLSSummary struct {
+	TotalSuccessful int64 `json:"total-successful-session-count"`
+	TotalFailure    int64 `json:"total-failure-session-count"`
+}
+
+// TLSFailureDetail describes a class of failed sessions
+type TLSFailureDetail struct {
+	ResultType            string `json:"result-type"`
+	SendingMTAIP          string `json:"sending-mta-ip"`
+	ReceivingMXHostname   string `json:"receiving-mx-hostname"`
+	ReceivingMXHelo       string `json:"receiving-mx-helo"`
+	ReceivingIP           string `json:"receiving-ip"`
+	FailedSessionCount    int64  `json:"failed-session-count"`
+	AdditionalInformation string `json:"additional-information"`
+	FailureReasonCode     string `json:"failure-reason-code"`
+}
+
+// StringList is a list of strings that also accepts a single JSON string,
+// since reporters disagree on whether mx-host is an array
+type StringList []string
+
+// UnmarshalJSON accepts either a string or an array of strings
+func (sl *StringList) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*sl = StringList{single}
+		return nil
+	}
+
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*sl = list
+	return nil
+}
+
+// AggregatedTLSReport represents an aggregated view of multiple TLS reports
+type AggregatedTLSReport struct {
+	TotalReports int
+	Successful   int64
+	Failed       int64
+	DateRange    DateRange
+	Policies     []TLSPolicyTotals
+	Failures     []TLSFailureTotals
+}
+
+// TLSPolicyTotals sums the sessions of one policy across reports
+type TLSPolicyTotals struct {
+	PolicyDomain string
+	PolicyType   string
+	Successful   int64
+	Failed       int64
+}
+
+// TLSFailureTotals sums the failed sessions of one receiving MTA and
+// result type across reports
+type TLSFailureTotals struct {
+	ReceivingMX string
+	ResultType  string
+	Failed      int64
+	Reporters   []string
+}
+
+// AggregateTLSReports combines multiple TLS reports into a single view
+func AggregateTLSReports(reports []TLSReport) AggregatedTLSReport {
+	aggr := AggregatedTLSReport{TotalReports: len(reports)}
+
+	type policyKey struct{ domain, policyType string }
+	type failureKey struct{ mx, resultType string }
+
+	policies := make(map[policyKey]*TLSPolicyTotals)
+	failures := make(map[failureKey]*TLSFailureTotals)
+	reporters := make(map[failureKey]map[string]bool)
+
+	for i, report := range reports {
+		if i == 0 || report.DateRange.Start.Before(aggr.DateRange.Begin) {
+			aggr.DateRange.Begin = report.DateRange.Start
+		}
+		if i == 0 || report.DateRange.End.After(aggr.DateRange.End) {
+			aggr.DateRange.End = report.DateRange.End
+		}
+
+		for _, result := range report.Policies {
+			aggr.Successful += result.Summary.TotalSuccessful
+			aggr.Failed += result.Summary.TotalFailure
+
+			pk := policyKey{result.Policy.PolicyDomain, result.Policy.PolicyType}
+			pt, ok := policies[pk]
+			if !ok {
+				pt = &TLSPolicyTotals{
+					PolicyDomain: pk.domain,
+					PolicyType:   pk.policyType,
+				}
+				policies[pk] = pt
+			}
+			pt.Successful += result.Summary.TotalSuccessful
+			pt.Failed += result.Summary.TotalFailure
+
+			for _, detail := range result.FailureDetails {
+				mx := detail.ReceivingMXHostname
+				if mx == "" {
+					mx = detail.ReceivingIP
+				}
+
+				fk := failureKey{mx, detail.ResultType}
+				ft, ok := failures[fk]
+				if !ok {
+					ft = &TLSFailureTotals{
+						ReceivingMX: fk.mx,
+						ResultType:  fk.resultType,
+					}
+					failures[fk] = ft
+					reporters[fk] = make(map[string]bool)
+				}
+				ft.Failed += detail.FailedSessionCount
+				if !reporters[fk][report.OrganizationName] {
+					reporters[fk][report.OrganizationName] = true
+					ft.Reporters = append(ft.Reporters, report.OrganizationName)
+				}
+			}
+		}
+	}
+
+	for _, pt := range policies {
+		aggr.Policies = append(aggr.Policies, *pt)
+	}
+	sort.Slice(aggr.Policies, func(i, j int) bool {
+		if aggr.Policies[i].PolicyDomain != aggr.Policies[j].PolicyDomain {
+			return aggr.Policies[i].PolicyDomain < aggr.Policies[j].PolicyDomain
+		}
+		return aggr.Policies[i].PolicyType < aggr.Policies[j].PolicyType
+	})
+
+	for _, ft := range failures {
+		sort.Strings(ft.Reporters)
+		aggr.Failures = append(aggr.Failures, *ft)
+	}
+	sort.Slice(aggr.Failures, func(i, j int) bool {
+		return aggr.Failures[i].Failed > aggr.Failures[j].Failed
+	})
+
+	return aggr
+}
@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// charsetReader is the xml.Decoder CharsetReader. It converts the legacy
+// encodings reporters use to UTF-8.
+func charsetReader(label string, input io.Reader) (io.Reader, error) {
+	label = strings.ToLower(strings.TrimSpace(label))
+
+	// UTF-16 input has already been converted by decodeBOM, since the XML
+	// declaration naming it could not have been read otherwise
+	if strings.HasPrefix(label, "utf-16") {
+		return input, nil
+	}
+
+	enc, err := lookupCharset(label)
+	if err != nil {
+		return nil, err
+	}
+
+	return transform.NewReader(input, enc.NewDecoder()), nil
+}
+
+// lookupCharset returns the encoding of a charset label. Labels are looked
+// up in the IANA registry, then among the aliases browsers accept, such as
+// cp1252.
+func lookupCharset(label string) (encoding.Encoding, error) {
+	enc, err := ianaindex.IANA.Encoding(label)
+	if enc == nil {
+		enc, err = htmlindex.Get(label)
+	}
+	if enc == nil || err != nil {
+		return nil, fmt.Errorf("unsupported charset: %s", label)
+	}
+
+	// Reporters declaring ASCII regularly send 8-bit bytes anyway, so
+	// ASCII is read as its most common superset
+	if name, _ := ianaindex.IANA.Name(enc); name == "US-ASCII" {
+		return charmap.Windows1252, nil
+	}
+	return enc, nil
+}
+
+// decodeBOM removes a byte order mark from the start of r and converts
+// UTF-16 input to UTF-8
+func decodeBOM(r *bufio.Reader) io.Reader {
+	head, _ := r.Peek(3)
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		r.Discard(3)
+		return r
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}),
+		bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		// The decoder reads the byte order from the BOM and drops it
+		utf16 := unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+		return transform.NewReader(r, utf16.NewDecoder())
+	default:
+		return r
+	}
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotFound is returned when a report file does not exist
+	ErrNotFound = errors.New("file not found")
+	// ErrEmpty is returned for empty or whitespace-only input
+	ErrEmpty = errors.New("file is empty")
+	// ErrNotXML is returned for input that does not look like an XML report
+	ErrNotXML = errors.New("not an XML document")
+)
+
+// SyntaxError reports malformed XML or JSON at a position in the input.
+// Line and Column start at 1 and are 0 if unknown.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+// Error implements error
+func (e *SyntaxError) Error() string {
+	if e.Line == 0 {
+		return "syntax error: " + e.Msg
+	}
+	return fmt.Sprintf(
+		"syntax error at line %d, column %d: %s",
+		e.Line,
+		e.Column,
+		e.Msg,
+	)
+}
+
+// SchemaError reports a well-formed document that is not a valid report,
+// such as a missing required field or a value of the wrong type
+type SchemaError struct {
+	// Field is the path of the offending element, e.g.
+	// "feedback/report_metadata/org_name" or "policies[0].policy"
+	Field string
+	Msg   string
+	// Err is the underlying decoding error, if any
+	Err error
+}
+
+// Error implements error
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Msg)
+}
+
+// Unwrap returns the underlying decoding error
+func (e *SchemaError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorCategory returns a short, human readable category for an error
+// returned by this package, suitable for grouping problems
+func ErrorCategory(err error) string {
+	var (
+		syntaxErr *SyntaxError
+		schemaErr *SchemaError
+		limitErr  *LimitError
+	)
+
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrNotFound):
+		return "not found"
+	case errors.Is(err, ErrEmpty):
+		return "empty"
+	case errors.Is(err, ErrNotXML):
+		return "not XML"
+	case errors.As(err, &syntaxErr):
+		return "malformed"
+	case errors.As(err, &schemaErr):
+		return "invalid report"
+	case errors.As(err, &limitErr):
+		return "limit exceeded"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,141 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+
+	"github.com/huhndev/godmarc/mailbox"
+	"github.com/huhndev/godmarc/model"
+)
+
+// ParseForensicReport parses a raw RFC 6591 failure report message
+func ParseForensicReport(r io.Reader) (model.ForensicReport, error) {
+	msg, err := mailbox.ParseMessage(r)
+	if err != nil {
+		return model.ForensicReport{}, err
+	}
+	return ParseForensicMessage(msg)
+}
+
+// ParseForensicMessage extracts an RFC 6591 failure report from a decoded
+// message. The message must contain a message/feedback-report part; the
+// original message or its headers are taken from a message/rfc822 or
+// text/rfc822-headers part if present.
+func ParseForensicMessage(msg mailbox.Message) (model.ForensicReport, error) {
+	report := model.ForensicReport{
+		Provenance: model.Provenance{
+			Date:      msg.Date,
+			From:      msg.From,
+			MessageID: msg.MessageID,
+		},
+	}
+
+	feedback, ok := msg.FeedbackReport()
+	if !ok {
+		return report, fmt.Errorf("message contains no feedback report")
+	}
+
+	fields, err := readFields(feedback.Data)
+	if err != nil {
+		return report, fmt.Errorf("invalid feedback report: %w", err)
+	}
+
+	report.FeedbackType = strings.ToLower(fields.Get("Feedback-Type"))
+	report.UserAgent = fields.Get("User-Agent")
+	report.Version = fields.Get("Version")
+	report.AuthFailure = strings.ToLower(fields.Get("Auth-Failure"))
+	report.SourceIP = model.NormalizeIP(fields.Get("Source-IP"))
+	report.OriginalMailFrom = trimAngles(fields.Get("Original-Mail-From"))
+	for _, rcpt := range fields.Values("Original-Rcpt-To") {
+		report.OriginalRcptTo = append(report.OriginalRcptTo, trimAngles(rcpt))
+	}
+	report.ReportedDomain = fields.Get("Reported-Domain")
+	report.DeliveryResult = fields.Get("Delivery-Result")
+	report.DKIMDomain = fields.Get("DKIM-Domain")
+	report.DKIMIdentity = fields.Get("DKIM-Identity")
+	report.DKIMSelector = fields.Get("DKIM-Selector")
+	report.SPFDNS = fields.Get("SPF-DNS")
+	report.IdentityAlign = fields.Get("Identity-Alignment")
+	report.AuthResults = fields.Values("Authentication-Results")
+
+	if arrival := fields.Get("Arrival-Date"); arrival != "" {
+		if date, err := mail.ParseDate(arrival); err == nil {
+			report.ArrivalDate = date
+		}
+	}
+
+	if report.FeedbackType == "" {
+		return report, &SchemaError{
+			Field: "Feedback-Type",
+			Msg:   "missing Feedback-Type",
+		}
+	}
+	if report.FeedbackType != "auth-failure" {
+		return report, &SchemaError{
+			Field: "Feedback-Type",
+			Msg:   fmt.Sprintf("unsupported feedback type %q", report.FeedbackType),
+		}
+	}
+
+	for _, att := range msg.Attachments {
+		if att.ContentType != "message/rfc822" &&
+			att.ContentType != "text/rfc822-headers" {
+			continue
+		}
+
+		report.OriginalHeaders = string(headerSection(att.Data))
+
+		headers, err := readFields(headerSection(att.Data))
+		if err == nil {
+			report.Subject = decodeHeader(headers.Get("Subject"))
+			report.HeaderFrom = decodeHeader(headers.Get("From"))
+			report.MessageID = headers.Get("Message-ID")
+		}
+		break
+	}
+
+	return report, nil
+}
+
+// readFields parses an RFC 5322 style header block, as used by both
+// feedback reports and message headers
+func readFields(data []byte) (textproto.MIMEHeader, error) {
+	// A header block must end with an empty line to be read completely
+	block := slices.Concat(bytes.TrimRight(data, "\r\n"), []byte("\r\n\r\n"))
+	tr := textproto.NewReader(bufio.NewReader(bytes.NewReader(block)))
+	return tr.ReadMIMEHeader()
+}
+
+// headerSection returns the header block of a message, i.e. everything up
+// to the first empty line
+func headerSection(data []byte) []byte {
+	data = bytes.TrimLeft(data, "\r\n")
+	if i := bytes.Index(data, []byte("\r\n\r\n")); i >= 0 {
+		return data[:i]
+	}
+	if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+		return data[:i]
+	}
+	return data
+}
+
+// trimAngles strips the angle brackets around an address
+func trimAngles(s string) string {
+	return strings.Trim(strings.TrimSpace(s), "<>")
+}
+
+// decodeHeader decodes RFC 2047 encoded words in a header value
+func decodeHeader(s string) string {
+	dec := new(mime.WordDecoder)
+	if decoded, err := dec.DecodeHeader(s); err == nil {
+		return decoded
+	}
+	return s
+}
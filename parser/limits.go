@@ -0,0 +1,159 @@
+package parser
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// Limits bounds the resources a single report may use while it is
+// parsed. A zero field means the default from DefaultLimits.
+type Limits struct {
+	// MaxCompressedSize limits the size of a gzip or zip file
+	MaxCompressedSize int64
+	// MaxDecompressedSize limits the size of a report document, and the
+	// total decompressed size of all entries of a zip archive
+	MaxDecompressedSize int64
+	// MaxArchiveEntries limits the number of entries in a zip archive
+	MaxArchiveEntries int
+	// MaxDepth limits how deeply XML elements may be nested
+	MaxDepth int
+	// MaxRecords limits the number of records in a report
+	MaxRecords int
+	// MaxAttrSize limits the length of an attribute value or of a run of
+	// text between tags
+	MaxAttrSize int
+}
+
+// DefaultLimits are generous enough for the reports of large providers
+// while still stopping decompression bombs and pathological documents
+var DefaultLimits = Limits{
+	MaxCompressedSize:   50 * 1024 * 1024,
+	MaxDecompressedSize: 200 * 1024 * 1024,
+	MaxArchiveEntries:   100,
+	MaxDepth:            32,
+	MaxRecords:          1000000,
+	MaxAttrSize:         64 * 1024,
+}
+
+// WithDefaults returns the limits with zero fields set from DefaultLimits
+func (l Limits) WithDefaults() Limits {
+	if l.MaxCompressedSize == 0 {
+		l.MaxCompressedSize = DefaultLimits.MaxCompressedSize
+	}
+	if l.MaxDecompressedSize == 0 {
+		l.MaxDecompressedSize = DefaultLimits.MaxDecompressedSize
+	}
+	if l.MaxArchiveEntries == 0 {
+		l.MaxArchiveEntries = DefaultLimits.MaxArchiveEntries
+	}
+	if l.MaxDepth == 0 {
+		l.MaxDepth = DefaultLimits.MaxDepth
+	}
+	if l.MaxRecords == 0 {
+		l.MaxRecords = DefaultLimits.MaxRecords
+	}
+	if l.MaxAttrSize == 0 {
+		l.MaxAttrSize = DefaultLimits.MaxAttrSize
+	}
+	return l
+}
+
+// LimitError is returned when input exceeds one of the Limits
+type LimitError struct {
+	// Limit is the name of the Limits field that was exceeded
+	Limit string
+	// Max is the value of that limit
+	Max int64
+}
+
+// Error implements error
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("input exceeds %s (limit %d)", e.Limit, e.Max)
+}
+
+// LimitReader returns a reader that reads from r but fails with a
+// *LimitError naming limit once more than max bytes have been read, so
+// oversized input is never silently truncated
+func LimitReader(r io.Reader, max int64, limit string) io.Reader {
+	return &limitedReader{
+		r:         r,
+		remaining: max,
+		err:       &LimitError{Limit: limit, Max: max},
+	}
+}
+
+// limitedReader is the reader returned by LimitReader
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+	err       *LimitError
+}
+
+// Read implements io.Reader
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, l.err
+	}
+
+	// Read one byte past the limit to tell input that ends exactly at the
+	// limit from input that exceeds it
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n + int(l.remaining), l.err
+	}
+	return n, err
+}
+
+// limitedTokenReader passes on the tokens of a decoder, failing once the
+// document nests too deeply or contains an oversized attribute or text
+type limitedTokenReader struct {
+	decoder *xml.Decoder
+	limits  Limits
+	depth   int
+}
+
+// Token implements xml.TokenReader
+func (t *limitedTokenReader) Token() (xml.Token, error) {
+	tok, err := t.decoder.Token()
+	if err != nil {
+		return tok, err
+	}
+
+	switch tok := tok.(type) {
+	case xml.StartElement:
+		t.depth++
+		if t.depth > t.limits.MaxDepth {
+			return nil, &LimitError{
+				Limit: "MaxDepth",
+				Max:   int64(t.limits.MaxDepth),
+			}
+		}
+		for _, attr := range tok.Attr {
+			if len(attr.Value) > t.limits.MaxAttrSize {
+				return nil, t.attrSizeError()
+			}
+		}
+	case xml.EndElement:
+		t.depth--
+	case xml.CharData:
+		if len(tok) > t.limits.MaxAttrSize {
+			return nil, t.attrSizeError()
+		}
+	}
+
+	return tok, nil
+}
+
+// attrSizeError returns the error for an oversized attribute or text
+func (t *limitedTokenReader) attrSizeError() error {
+	return &LimitError{
+		Limit: "MaxAttrSize",
+		Max:   int64(t.limits.MaxAttrSize),
+	}
+}
@@ -1,79 +1,437 @@
 package parser
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/huhndev/godmarc/model"
 )
 
-// ParseDMARCReport parses a DMARC report XML file
+// dateRangeField is the path of a report's date range
+const dateRangeField = "feedback/report_metadata/date_range"
+
+// sniffSize is how much of a report is inspected to tell whether it looks
+// like XML before decoding starts
+const sniffSize = 1024
+
+// Parser parses reports while enforcing a set of Limits
+type Parser struct {
+	limits Limits
+
+	// Lenient keeps the parts of a DMARC report that could be read
+	// instead of rejecting the whole report. Records that fail to decode
+	// are skipped, malformed XML ends the report early, and an invalid
+	// date range is accepted. Such reports are marked as degraded and list
+	// their problems.
+	Lenient bool
+}
+
+// NewParser creates a Parser enforcing limits. Zero fields of limits take
+// the value from DefaultLimits.
+func NewParser(limits Limits) *Parser {
+	return &Parser{limits: limits.WithDefaults()}
+}
+
+// Limits returns the limits the parser enforces
+func (p *Parser) Limits() Limits {
+	return p.limits
+}
+
+// defaultParser backs the package-level parse functions
+var defaultParser = NewParser(DefaultLimits)
+
+// ParseDMARCReport parses a DMARC report XML file within DefaultLimits
 func ParseDMARCReport(filepath string) (model.DMARCReport, error) {
+	return defaultParser.ParseDMARCReport(filepath)
+}
+
+// ParseDMARCReportData parses a DMARC report from XML held in memory
+// within DefaultLimits
+func ParseDMARCReportData(data []byte, name string) (model.DMARCReport, error) {
+	return defaultParser.ParseDMARCReportData(data, name)
+}
+
+// ParseDMARCReportReader parses a DMARC report from an XML stream within
+// DefaultLimits
+func ParseDMARCReportReader(r io.Reader, name string) (model.DMARCReport, error) {
+	return defaultParser.ParseDMARCReportReader(r, name)
+}
+
+// ParseDMARCReport parses a DMARC report XML file
+func (p *Parser) ParseDMARCReport(filepath string) (model.DMARCReport, error) {
 	var report model.DMARCReport
 
 	// Validate file exists
 	info, err := os.Stat(filepath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return report, fmt.Errorf("file not found: %s", filepath)
+			return report, fmt.Errorf("%w: %s", ErrNotFound, filepath)
 		}
 		return report, fmt.Errorf("error accessing file %s: %w", filepath, err)
 	}
 
 	// Check file size
 	if info.Size() == 0 {
-		return report, fmt.Errorf("file is empty: %s", filepath)
+		return report, fmt.Errorf("%w: %s", ErrEmpty, filepath)
 	}
 
-	// Read file with more context if it fails
-	data, err := os.ReadFile(filepath)
+	f, err := os.Open(filepath)
 	if err != nil {
 		return report, fmt.Errorf("could not read file %s: %w", filepath, err)
 	}
+	defer f.Close()
 
-	// Check if data seems to be XML
-	if !hasXMLHeader(data) && !hasRootElement(data) {
-		return report, fmt.Errorf(
-			"file %s does not appear to be valid XML",
-			filepath,
-		)
+	return p.ParseDMARCReportReader(f, filepath)
+}
+
+// ParseDMARCReportData parses a DMARC report from XML held in memory. The
+// name identifies the report's origin in error messages.
+func (p *Parser) ParseDMARCReportData(
+	data []byte,
+	name string,
+) (model.DMARCReport, error) {
+	return p.ParseDMARCReportReader(bytes.NewReader(data), name)
+}
+
+// ParseDMARCReportReader parses a DMARC report from an XML stream. Records
+// are decoded one at a time as they are read, so the raw document is never
+// held in memory. The name identifies the report's origin in error
+// messages.
+func (p *Parser) ParseDMARCReportReader(
+	r io.Reader,
+	name string,
+) (model.DMARCReport, error) {
+	var report model.DMARCReport
+
+	// Fail on oversized documents instead of parsing a truncated one
+	r = LimitReader(r, p.limits.MaxDecompressedSize, "MaxDecompressedSize")
+
+	// UTF-16 must be converted before even the XML declaration can be read
+	br := bufio.NewReaderSize(decodeBOM(bufio.NewReader(r)), sniffSize)
+
+	// Look at the start of the document without consuming it
+	head, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return report, fmt.Errorf("could not read file %s: %w", name, err)
 	}
 
-	// Handle possible XML declaration
-	data = []byte(strings.TrimSpace(string(data)))
+	if len(bytes.TrimSpace(head)) == 0 && len(head) < sniffSize {
+		return report, fmt.Errorf("%w: %s", ErrEmpty, name)
+	}
+
+	// Check if data seems to be XML
+	if !hasXMLHeader(head) && !hasRootElement(head) {
+		return report, fmt.Errorf("%w: %s", ErrNotXML, name)
+	}
 
-	// Create a secure XML decoder with entity expansion disabled
-	decoder := xml.NewDecoder(bytes.NewReader(data))
+	// The decoder never expands entities declared in a DTD, so neither
+	// XXE nor billion laughs apply. Only the predefined XML entities are
+	// recognized, which Strict mode enforces.
+	raw := xml.NewDecoder(br)
+	raw.Strict = true
 
-	// Disable entity expansion to prevent XXE attacks
-	decoder.Entity = xml.HTMLEntity
+	// Convert reports declared in legacy encodings to UTF-8
+	raw.CharsetReader = charsetReader
 
-	// Impose reasonable limits to prevent billion laughs attack
-	decoder.Strict = true
+	// Enforce depth and size limits on every token
+	tokens := &limitedTokenReader{decoder: raw, limits: p.limits}
+	decoder := xml.NewTokenDecoder(tokens)
 
-	// Parse XML using the secure decoder
-	err = decoder.Decode(&report)
+	// Find the root element so its namespace can be inspected
+	root, err := findRootElement(decoder)
 	if err != nil {
-		// Add more context to XML parsing errors
-		return report, fmt.Errorf("invalid XML in file %s: %w", filepath, err)
+		return report, decodeError(name, raw, err)
+	}
+
+	body := &reportDecoder{
+		decoder: decoder,
+		tokens:  tokens,
+		limits:  p.limits,
+		lenient: p.Lenient,
+		report:  &report,
+	}
+	if err := body.decode(); err != nil {
+		return report, decodeError(name, raw, err)
 	}
 
+	report.Schema = detectSchema(root, report)
+
 	// Validate required fields
 	if err := validateReport(report); err != nil {
-		return report, fmt.Errorf(
-			"invalid DMARC report in file %s: %w",
-			filepath,
-			err,
-		)
+		var schemaErr *SchemaError
+		if p.Lenient && errors.As(err, &schemaErr) &&
+			schemaErr.Field == dateRangeField {
+			// A date range that failed to decode, alone or with the rest
+			// of the report metadata, is already a problem
+			if !hasProblem(
+				report,
+				dateRangeField,
+				"feedback/report_metadata",
+			) {
+				report.AddProblem(model.ReportProblem{
+					Field: schemaErr.Field,
+					Msg:   schemaErr.Msg,
+				})
+			}
+		} else {
+			return report, fmt.Errorf(
+				"invalid DMARC report in file %s: %w",
+				name,
+				err,
+			)
+		}
 	}
 
 	return report, nil
 }
 
+// hasProblem reports whether a problem was recorded for any of fields
+func hasProblem(report model.DMARCReport, fields ...string) bool {
+	for _, problem := range report.Problems {
+		if slices.Contains(fields, problem.Field) {
+			return true
+		}
+	}
+	return false
+}
+
+// reportDecoder decodes the children of a report's root element
+type reportDecoder struct {
+	decoder *xml.Decoder
+	tokens  *limitedTokenReader
+	limits  Limits
+	lenient bool
+	report  *model.DMARCReport
+	// records counts the records in the document, including those that
+	// could not be decoded
+	records int
+}
+
+// decode decodes the children of the root element one by one until the
+// root element ends. Each record is decoded on its own, so memory use does
+// not depend on the size of the document.
+func (d *reportDecoder) decode() error {
+	for {
+		tok, err := d.decoder.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return d.truncated(err)
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if err := d.decodeElement(t); err != nil {
+				return d.truncated(err)
+			}
+		case xml.EndElement:
+			// The strict decoder guarantees this is the root's end tag
+			return nil
+		}
+	}
+}
+
+// decodeElement decodes a top-level element of a report into the matching
+// field. Unknown elements are skipped.
+func (d *reportDecoder) decodeElement(start xml.StartElement) error {
+	field := "feedback/" + start.Name.Local
+
+	var err error
+	switch start.Name.Local {
+	case "version":
+		err = d.decoder.DecodeElement(&d.report.Version, &start)
+	case "report_metadata":
+		err = d.decoder.DecodeElement(&d.report.ReportMetadata, &start)
+	case "policy_published":
+		err = d.decoder.DecodeElement(&d.report.PolicyPublished, &start)
+	case "record":
+		if d.records >= d.limits.MaxRecords {
+			return &LimitError{
+				Limit: "MaxRecords",
+				Max:   int64(d.limits.MaxRecords),
+			}
+		}
+		d.records++
+		field = fmt.Sprintf("%s[%d]", field, d.records)
+
+		var record model.Record
+		err = d.decoder.DecodeElement(&record, &start)
+		if err == nil {
+			if d.lenient {
+				d.checkRecord(record, field)
+			}
+			d.report.Records = append(d.report.Records, record)
+			return nil
+		}
+		return d.recover(d.records, fieldError(field, err))
+	default:
+		return d.decoder.Skip()
+	}
+
+	return d.recover(0, fieldError(field, err))
+}
+
+// recover handles an error decoding a top-level element. In lenient mode
+// a *SchemaError is recorded as a problem and the rest of the element is
+// skipped; otherwise the error is returned.
+func (d *reportDecoder) recover(record int, err error) error {
+	var schemaErr *SchemaError
+	if err == nil || !d.lenient || !errors.As(err, &schemaErr) {
+		return err
+	}
+
+	d.report.AddProblem(model.ReportProblem{
+		Record:  record,
+		Field:   schemaErr.Field,
+		Msg:     schemaErr.Msg,
+		Skipped: record > 0,
+	})
+
+	// Skip what is left of the element that failed to decode
+	for d.tokens.depth > 1 {
+		if _, err := d.decoder.Token(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// truncated handles malformed XML. In lenient mode everything decoded up
+// to that point is kept and the rest of the document is given up.
+func (d *reportDecoder) truncated(err error) error {
+	var xmlErr *xml.SyntaxError
+	if !d.lenient ||
+		(err != io.ErrUnexpectedEOF && !errors.As(err, &xmlErr)) {
+		return err
+	}
+
+	msg := "unexpected EOF"
+	if xmlErr != nil {
+		msg = xmlErr.Msg
+	}
+
+	d.report.AddProblem(model.ReportProblem{
+		Field: "feedback",
+		Msg: fmt.Sprintf(
+			"document breaks off after %d complete records, the rest was ignored: %v",
+			len(d.report.Records),
+			newSyntaxError(d.tokens.decoder, msg),
+		),
+	})
+
+	return nil
+}
+
+// knownDispositions are the dispositions a record may report
+var knownDispositions = map[string]bool{
+	"none":       true,
+	"quarantine": true,
+	"reject":     true,
+	"pass":       true,
+}
+
+// checkRecord records problems with a record that decoded but carries
+// values the report format does not allow
+func (d *reportDecoder) checkRecord(record model.Record, field string) {
+	disposition := record.Row.PolicyEvaluated.Disposition
+	if !knownDispositions[disposition] {
+		d.report.AddProblem(model.ReportProblem{
+			Record: d.records,
+			Field:  field + "/row/policy_evaluated/disposition",
+			Msg:    fmt.Sprintf("unknown disposition %q", disposition),
+		})
+	}
+}
+
+// fieldError turns an error decoding the element at field into a
+// *SchemaError, unless the XML itself is malformed or a limit was hit
+func fieldError(field string, err error) error {
+	var (
+		limitErr *LimitError
+		xmlErr   *xml.SyntaxError
+	)
+
+	if err == nil || err == io.EOF || err == io.ErrUnexpectedEOF ||
+		errors.As(err, &limitErr) || errors.As(err, &xmlErr) {
+		return err
+	}
+
+	return &SchemaError{Field: field, Msg: err.Error(), Err: err}
+}
+
+// decodeError adds context to an error from decoding a report. Malformed
+// XML becomes a *SyntaxError positioned where the raw decoder stopped.
+func decodeError(name string, raw *xml.Decoder, err error) error {
+	var (
+		limitErr  *LimitError
+		schemaErr *SchemaError
+		xmlErr    *xml.SyntaxError
+	)
+
+	switch {
+	case errors.As(err, &limitErr):
+		return fmt.Errorf("report %s rejected: %w", name, err)
+	case errors.As(err, &schemaErr):
+		return fmt.Errorf("invalid DMARC report in file %s: %w", name, err)
+	case errors.As(err, &xmlErr):
+		err = newSyntaxError(raw, xmlErr.Msg)
+	case err == io.EOF:
+		err = newSyntaxError(raw, "no root element")
+	case err == io.ErrUnexpectedEOF:
+		err = newSyntaxError(raw, "unexpected EOF")
+	}
+
+	return fmt.Errorf("invalid XML in file %s: %w", name, err)
+}
+
+// newSyntaxError creates a *SyntaxError at the raw decoder's position
+func newSyntaxError(raw *xml.Decoder, msg string) *SyntaxError {
+	line, column := raw.InputPos()
+	return &SyntaxError{Line: line, Column: column, Msg: msg}
+}
+
+// findRootElement advances the decoder to the document's root element
+func findRootElement(decoder *xml.Decoder) (xml.StartElement, error) {
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return xml.StartElement{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start, nil
+		}
+	}
+}
+
+// detectSchema determines whether a report uses the RFC 7489 or the
+// DMARCbis aggregate format. The namespace is authoritative; reports
+// without one are recognized by the fields only DMARCbis defines.
+func detectSchema(
+	root xml.StartElement,
+	report model.DMARCReport,
+) model.SchemaVersion {
+	if root.Name.Space == model.DMARCbisNamespace {
+		return model.SchemaDMARCbis
+	}
+
+	if report.ReportMetadata.Generator != "" ||
+		report.PolicyPublished.HasDMARCbisFields() {
+		return model.SchemaDMARCbis
+	}
+
+	return model.SchemaRFC7489
+}
+
 // hasXMLHeader checks if the data starts with an XML declaration
 func hasXMLHeader(data []byte) bool {
 	return bytes.HasPrefix(bytes.TrimSpace(data), []byte("<?xml"))
@@ -82,28 +440,42 @@ func hasXMLHeader(data []byte) bool {
 // hasRootElement checks if the data contains what appears to be an XML root element
 func hasRootElement(data []byte) bool {
 	s := string(bytes.TrimSpace(data))
-	return strings.Contains(s, "<feedback") || strings.Contains(s, "<report")
+	return strings.Contains(s, "<feedback") || strings.Contains(s, ":feedback") ||
+		strings.Contains(s, "<report")
 }
 
 // validateReport checks that essential fields are present
 func validateReport(report model.DMARCReport) error {
 	if report.ReportMetadata.ReportID == "" {
-		return fmt.Errorf("missing report ID")
+		return &SchemaError{
+			Field: "feedback/report_metadata/report_id",
+			Msg:   "missing report ID",
+		}
 	}
 
 	if report.ReportMetadata.OrgName == "" {
-		return fmt.Errorf("missing organization name")
+		return &SchemaError{
+			Field: "feedback/report_metadata/org_name",
+			Msg:   "missing organization name",
+		}
 	}
 
 	if report.PolicyPublished.Domain == "" {
-		return fmt.Errorf("missing domain in policy")
+		return &SchemaError{
+			Field: "feedback/policy_published/domain",
+			Msg:   "missing domain in policy",
+		}
 	}
 
-	// Make sure we have a valid date range
+	// Make sure we have a valid date range. This is checked last, since
+	// lenient parsing tolerates it.
 	zeroTime := time.Time{}
 	if report.ReportMetadata.DateRange.Begin == zeroTime ||
 		report.ReportMetadata.DateRange.End == zeroTime {
-		return fmt.Errorf("invalid date range")
+		return &SchemaError{
+			Field: dateRangeField,
+			Msg:   "invalid date range",
+		}
 	}
 
 	return nil
@@ -0,0 +1,123 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/huhndev/godmarc/model"
+)
+
+// ParseTLSReportData parses an RFC 8460 TLS report from JSON held in
+// memory within DefaultLimits
+func ParseTLSReportData(data []byte, name string) (model.TLSReport, error) {
+	return defaultParser.ParseTLSReportData(data, name)
+}
+
+// ParseTLSReportData parses an RFC 8460 TLS report from JSON held in
+// memory. The name identifies the report's origin in error messages.
+func (p *Parser) ParseTLSReportData(
+	data []byte,
+	name string,
+) (model.TLSReport, error) {
+	var report model.TLSReport
+
+	if int64(len(data)) > p.limits.MaxDecompressedSize {
+		return report, fmt.Errorf("report %s rejected: %w", name, &LimitError{
+			Limit: "MaxDecompressedSize",
+			Max:   p.limits.MaxDecompressedSize,
+		})
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return report, fmt.Errorf("%w: %s", ErrEmpty, name)
+	}
+
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf(
+			"invalid JSON in file %s: %w",
+			name,
+			jsonError(data, err),
+		)
+	}
+
+	if err := validateTLSReport(report); err != nil {
+		return report, fmt.Errorf(
+			"invalid TLS report in file %s: %w",
+			name,
+			err,
+		)
+	}
+
+	return report, nil
+}
+
+// jsonError converts an error from encoding/json into a *SyntaxError or
+// *SchemaError
+func jsonError(data []byte, err error) error {
+	var (
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+	)
+
+	switch {
+	case errors.As(err, &syntaxErr):
+		line, column := position(data, syntaxErr.Offset)
+		return &SyntaxError{Line: line, Column: column, Msg: syntaxErr.Error()}
+	case errors.As(err, &typeErr):
+		field := typeErr.Field
+		if field == "" {
+			field = "(root)"
+		}
+		return &SchemaError{
+			Field: field,
+			Msg:   fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value),
+			Err:   err,
+		}
+	case err == io.ErrUnexpectedEOF:
+		line, column := position(data, int64(len(data)))
+		return &SyntaxError{Line: line, Column: column, Msg: "unexpected EOF"}
+	default:
+		return err
+	}
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	offset = min(offset, int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// validateTLSReport checks that essential fields are present
+func validateTLSReport(report model.TLSReport) error {
+	if report.ReportID == "" {
+		return &SchemaError{Field: "report-id", Msg: "missing report ID"}
+	}
+
+	if report.OrganizationName == "" {
+		return &SchemaError{
+			Field: "organization-name",
+			Msg:   "missing organization name",
+		}
+	}
+
+	if report.DateRange.Start.IsZero() || report.DateRange.End.IsZero() {
+		return &SchemaError{Field: "date-range", Msg: "invalid date range"}
+	}
+
+	for i, result := range report.Policies {
+		if result.Policy.PolicyType == "" {
+			return &SchemaError{
+				Field: fmt.Sprintf("policies[%d].policy.policy-type", i),
+				Msg:   "missing policy type",
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+// Package provider classifies the sources of email by the known email
+// services (Google Workspace, Microsoft 365, SendGrid, ...) they belong to.
+package provider
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+// builtin is the ruleset shipped with godmarc. Its networks are taken from
+// the SPF records the providers publish.
+//
+//go:embed providers.json
+var builtin []byte
+
+// Provider is a known email service and the rules matching its mail
+type Provider struct {
+	Name string `json:"name"`
+	// Networks are the IP addresses and CIDR ranges it sends from
+	Networks []string `json:"networks,omitempty"`
+	// PTR are the domains the reverse DNS names of its servers end in
+	PTR []string `json:"ptr,omitempty"`
+	// DKIM are the d= domains it signs with, subdomains included
+	DKIM []string `json:"dkim,omitempty"`
+}
+
+// ruleFile is the format of a ruleset file
+type ruleFile struct {
+	Providers []Provider `json:"providers"`
+}
+
+// Ruleset is a list of providers, matched in order
+type Ruleset struct {
+	providers []rules
+}
+
+// rules are the parsed rules of a provider
+type rules struct {
+	name     string
+	networks []netip.Prefix
+	ptr      []string
+	dkim     []string
+}
+
+// Parse reads a ruleset in JSON, an object with a "providers" list
+func Parse(r io.Reader) (*Ruleset, error) {
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+
+	var file ruleFile
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("invalid provider rules: %w", err)
+	}
+
+	rs := &Ruleset{providers: make([]rules, 0, len(file.Providers))}
+	for i, p := range file.Providers {
+		name := strings.TrimSpace(p.Name)
+		if name == "" {
+			return nil, fmt.Errorf("provider %d has no name", i+1)
+		}
+
+		compiled := rules{name: name}
+		for _, network := range p.Networks {
+			prefix, err := parseNetwork(network)
+			if err != nil {
+				return nil, fmt.Errorf("provider %s: %w", name, err)
+			}
+			compiled.networks = append(compiled.networks, prefix)
+		}
+		for _, suffix := range p.PTR {
+			compiled.ptr = append(compiled.ptr, normalize(suffix))
+		}
+		for _, domain := range p.DKIM {
+			compiled.dkim = append(compiled.dkim, normalize(domain))
+		}
+		rs.providers = append(rs.providers, compiled)
+	}
+
+	return rs, nil
+}
+
+// LoadFile reads a ruleset from a file
+func LoadFile(path string) (*Ruleset, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open provider rules: %w", err)
+	}
+	defer f.Close()
+
+	rs, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// DefaultFile returns the file the user's own rules are read from,
+// providers.json in the godmarc user configuration directory
+// (~/.config/godmarc on Linux), or "" if there is none
+func DefaultFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "godmarc", "providers.json")
+}
+
+// Combine returns a ruleset matching the providers of the rulesets in
+// order, so earlier rulesets take precedence
+func Combine(rulesets ...*Ruleset) *Ruleset {
+	combined := &Ruleset{}
+	for _, rs := range rulesets {
+		combined.providers = append(combined.providers, rs.providers...)
+	}
+	return combined
+}
+
+// Match returns the name of the provider of a source, or "" if none
+// matches. The source IP address is matched first, then its reverse DNS
+// names and then the domains of passing DKIM signatures.
+func (rs *Ruleset) Match(ip string, ptrNames, dkimDomains []string) string {
+	if addr, err := netip.ParseAddr(strings.TrimSpace(ip)); err == nil {
+		if name := rs.matchNetwork(addr.Unmap()); name != "" {
+			return name
+		}
+	}
+
+	for _, p := range rs.providers {
+		for _, name := range ptrNames {
+			if hasDomainSuffix(name, p.ptr) {
+				return p.name
+			}
+		}
+	}
+
+	for _, p := range rs.providers {
+		for _, domain := range dkimDomains {
+			if hasDomainSuffix(domain, p.dkim) {
+				return p.name
+			}
+		}
+	}
+
+	return ""
+}
+
+// matchNetwork returns the name of the first provider with a network
+// holding an IP address, or "" if none does
+func (rs *Ruleset) matchNetwork(addr netip.Addr) string {
+	for _, p := range rs.providers {
+		for _, network := range p.networks {
+			if network.Contains(addr) {
+				return p.name
+			}
+		}
+	}
+	return ""
+}
+
+// parseNetwork parses a CIDR range or a single IP address
+func parseNetwork(network string) (netip.Prefix, error) {
+	network = strings.TrimSpace(network)
+	if strings.Contains(network, "/") {
+		prefix, err := netip.ParsePrefix(network)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q", network)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(network)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid network %q", network)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// hasDomainSuffix reports whether a domain is one of the domains or a
+// subdomain of one
+func hasDomainSuffix(domain string, suffixes []string) bool {
+	domain = normalize(domain)
+	if domain == "" {
+		return false
+	}
+	for _, suffix := range suffixes {
+		if domain == suffix || strings.HasSuffix(domain, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize lowercases a domain and strips surrounding space and a
+// trailing dot
+func normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+var (
+	embedded = mustParse(builtin)
+	current  atomic.Pointer[Ruleset]
+)
+
+// mustParse parses the built-in ruleset
+func mustParse(data []byte) *Ruleset {
+	rs, err := Parse(bytes.NewReader(data))
+	if err != nil {
+		panic("provider: built-in rules: " + err.Error())
+	}
+	return rs
+}
+
+// Embedded returns the built-in ruleset
+func Embedded() *Ruleset {
+	return embedded
+}
+
+// Default returns the ruleset used by Classify, the built-in one unless
+// SetDefault replaced it
+func Default() *Ruleset {
+	if rs := current.Load(); rs != nil {
+		return rs
+	}
+	return embedded
+}
+
+// SetDefault replaces the ruleset used by Classify. A nil ruleset restores
+// the built-in one.
+func SetDefault(rs *Ruleset) {
+	current.Store(rs)
+}
+
+// LoadDefault extends the built-in rules with the user's own from path, or
+// from DefaultFile if path is empty, and makes them the default. A missing
+// DefaultFile is not an error.
+func LoadDefault(path string) error {
+	if path == "" {
+		path = DefaultFile()
+		if path == "" {
+			return nil
+		}
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+	}
+
+	rs, err := LoadFile(path)
+	if err != nil {
+		return err
+	}
+	SetDefault(Combine(rs, embedded))
+	return nil
+}
+
+// Classify returns the name of the provider of a source with the default
+// ruleset and the reverse DNS names found by Resolve, or "" if unknown.
+// dkimDomains are the domains of the passing DKIM signatures of its mail.
+func Classify(ip string, dkimDomains ...string) string {
+	return Default().Match(ip, PTRNames(ip), dkimDomains)
+}
@@ -0,0 +1,132 @@
+package provider
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Reverse DNS lookups run this many at a time, each giving up after
+// lookupTimeout
+const (
+	resolveWorkers = 16
+	lookupTimeout  = 3 * time.Second
+)
+
+var (
+	resolvedMu sync.RWMutex
+	// resolved holds the confirmed PTR names per address looked up,
+	// empty if none were found
+	resolved = make(map[netip.Addr][]string)
+)
+
+// PTRNames returns the reverse DNS names Resolve found for an IP address
+func PTRNames(ip string) []string {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return nil
+	}
+
+	resolvedMu.RLock()
+	defer resolvedMu.RUnlock()
+	return resolved[addr.Unmap()]
+}
+
+// Resolve looks up the reverse DNS names of the IP addresses that no
+// network rule of the default ruleset matches, so PTR rules can match them.
+// Only names that resolve back to the address are kept, as anyone can
+// publish any PTR name for their own addresses. Addresses looked up before
+// are skipped, and lookups stop when ctx is done.
+func Resolve(ctx context.Context, ips []string) {
+	rs := Default()
+
+	resolvedMu.RLock()
+	seen := make(map[netip.Addr]bool)
+	var pending []netip.Addr
+	for _, ip := range ips {
+		addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+		if _, ok := resolved[addr]; ok || seen[addr] {
+			continue
+		}
+		seen[addr] = true
+		if rs.matchNetwork(addr) == "" {
+			pending = append(pending, addr)
+		}
+	}
+	resolvedMu.RUnlock()
+
+	jobs := make(chan netip.Addr)
+	var wg sync.WaitGroup
+	for range min(resolveWorkers, len(pending)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for addr := range jobs {
+				names, ok := lookupPTR(ctx, addr)
+				if !ok {
+					continue
+				}
+				resolvedMu.Lock()
+				resolved[addr] = names
+				resolvedMu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, addr := range pending {
+		select {
+		case jobs <- addr:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// lookupPTR returns the reverse DNS names of an address that resolve back
+// to it. It is not ok if the lookup was cut short, so it may be retried.
+func lookupPTR(ctx context.Context, addr netip.Addr) ([]string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, lookupTimeout)
+	defer cancel()
+
+	names, err := net.DefaultResolver.LookupAddr(ctx, addr.String())
+	if err != nil {
+		if ctx.Err() != nil || isTemporary(err) {
+			return nil, false
+		}
+		return []string{}, true
+	}
+
+	confirmed := []string{}
+	for _, name := range names {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", name)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, false
+			}
+			continue
+		}
+		for _, a := range addrs {
+			if a.Unmap() == addr {
+				confirmed = append(confirmed, normalize(name))
+				break
+			}
+		}
+	}
+	return confirmed, true
+}
+
+// isTemporary reports whether a DNS error may go away on retry
+func isTemporary(err error) bool {
+	dnsErr, ok := err.(*net.DNSError)
+	return ok && (dnsErr.IsTimeout || dnsErr.IsTemporary)
+}
@@ -0,0 +1,209 @@
+// Package publicsuffix finds the organizational domain of a domain name
+// with the Public Suffix List (https://publicsuffix.org).
+package publicsuffix
+
+import (
+	"bufio"
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/net/idna"
+)
+
+// snapshot is the list embedded at build time
+//
+//go:embed public_suffix_list.dat
+var snapshot string
+
+// Rule flags of a domain in a List
+const (
+	// ruleNormal marks a domain that is a public suffix
+	ruleNormal uint8 = 1 << iota
+	// ruleWildcard marks a domain whose direct subdomains are public
+	// suffixes, written *.domain
+	ruleWildcard
+	// ruleException marks a domain excepted from a wildcard rule, written
+	// !domain
+	ruleException
+)
+
+// List is a parsed Public Suffix List. Both its ICANN and private sections
+// are used, so e.g. each user site on a hosting domain listed in the
+// private section is its own organizational domain.
+type List struct {
+	rules map[string]uint8
+}
+
+// Parse reads a list in the public_suffix_list.dat format
+func Parse(r io.Reader) (*List, error) {
+	list := &List{rules: make(map[string]uint8)}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		// A rule is the first field of a line; comments start with //
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "//") {
+			continue
+		}
+		rule := strings.ToLower(fields[0])
+
+		flag := ruleNormal
+		switch {
+		case strings.HasPrefix(rule, "!"):
+			flag, rule = ruleException, rule[1:]
+		case strings.HasPrefix(rule, "*."):
+			flag, rule = ruleWildcard, rule[2:]
+		}
+
+		// Reports carry domains as A-labels, so match rules in that form
+		ascii, err := idna.Lookup.ToASCII(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %w", fields[0], err)
+		}
+		list.rules[ascii] |= flag
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read public suffix list: %w", err)
+	}
+	if len(list.rules) == 0 {
+		return nil, fmt.Errorf("public suffix list has no rules")
+	}
+
+	return list, nil
+}
+
+// LoadFile reads a list from a file, e.g. a newer copy of
+// public_suffix_list.dat than the embedded one
+func LoadFile(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open public suffix list: %w", err)
+	}
+	defer f.Close()
+
+	list, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return list, nil
+}
+
+// PublicSuffix returns the public suffix of a domain, the part under which
+// names are registered. A domain matching no rule has its top-level label
+// as public suffix.
+func (l *List) PublicSuffix(domain string) string {
+	suffix, _ := l.publicSuffix(normalize(domain))
+	return suffix
+}
+
+// publicSuffix returns the public suffix of a normalized domain and
+// whether a rule of the list matched it
+func (l *List) publicSuffix(domain string) (string, bool) {
+	labels := strings.Split(domain, ".")
+
+	// The first match from the left is the longest
+	for i := range labels {
+		suffix := strings.Join(labels[i:], ".")
+		flags := l.rules[suffix]
+		if flags&ruleException != 0 {
+			return strings.Join(labels[i+1:], "."), true
+		}
+		if flags&ruleNormal != 0 {
+			return suffix, true
+		}
+		if i+1 < len(labels) &&
+			l.rules[strings.Join(labels[i+1:], ".")]&ruleWildcard != 0 {
+			return suffix, true
+		}
+	}
+	return labels[len(labels)-1], false
+}
+
+// IsRegistered reports whether a domain is a name below a listed public
+// suffix, like example.com or mail.example.co.uk, rather than a public
+// suffix itself, a name under an unlisted top-level domain or no domain
+// name at all
+func (l *List) IsRegistered(domain string) bool {
+	domain = normalize(domain)
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" || strings.ContainsAny(label, " \t/:@") {
+			return false
+		}
+	}
+
+	suffix, listed := l.publicSuffix(domain)
+	return listed && len(domain) > len(suffix)
+}
+
+// OrganizationalDomain returns the organizational domain of a domain: its
+// public suffix and one more label. A domain that is a public suffix
+// itself is returned as is.
+func (l *List) OrganizationalDomain(domain string) string {
+	domain = normalize(domain)
+	suffix := l.PublicSuffix(domain)
+	if len(domain) <= len(suffix) {
+		return domain
+	}
+
+	rest := strings.TrimSuffix(domain, "."+suffix)
+	if i := strings.LastIndexByte(rest, '.'); i >= 0 {
+		rest = rest[i+1:]
+	}
+	return rest + "." + suffix
+}
+
+// normalize lowercases a domain and strips surrounding space and a
+// trailing dot
+func normalize(domain string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+}
+
+var (
+	embedded     *List
+	embeddedOnce sync.Once
+	current      atomic.Pointer[List]
+)
+
+// Embedded returns the list embedded at build time
+func Embedded() *List {
+	embeddedOnce.Do(func() {
+		list, err := Parse(strings.NewReader(snapshot))
+		if err != nil {
+			panic("publicsuffix: embedded list: " + err.Error())
+		}
+		embedded = list
+	})
+	return embedded
+}
+
+// Default returns the list used by OrganizationalDomain, the embedded one
+// unless SetDefault replaced it
+func Default() *List {
+	if list := current.Load(); list != nil {
+		return list
+	}
+	return Embedded()
+}
+
+// SetDefault replaces the list used by OrganizationalDomain. A nil list
+// restores the embedded one.
+func SetDefault(list *List) {
+	current.Store(list)
+}
+
+// OrganizationalDomain returns the organizational domain of a domain with
+// the default list
+func OrganizationalDomain(domain string) string {
+	return Default().OrganizationalDomain(domain)
+}
+
+// IsRegistered reports whether a domain is a name below a public suffix of
+// the default list
+func IsRegistered(domain string) bool {
+	return Default().IsRegistered(domain)
+}
@@ -0,0 +1,153 @@
+package publicsuffix
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOrganizationalDomain(t *testing.T) {
+	tests := []struct {
+		domain string
+		suffix string
+		org    string
+	}{
+		// ICANN rules
+		{"example.com", "com", "example.com"},
+		{"mail.example.com", "com", "example.com"},
+		{"a.b.example.co.uk", "co.uk", "example.co.uk"},
+		{"example.co.uk", "co.uk", "example.co.uk"},
+
+		// Normalization
+		{"Mail.Example.COM.", "com", "example.com"},
+		{" example.org ", "org", "example.org"},
+
+		// A bare public suffix is its own organizational domain
+		{"com", "com", "com"},
+		{"co.uk", "co.uk", "co.uk"},
+
+		// Wildcard and exception rules: *.kawasaki.jp, !city.kawasaki.jp
+		{"foo.bar.kawasaki.jp", "bar.kawasaki.jp", "foo.bar.kawasaki.jp"},
+		{"x.foo.bar.kawasaki.jp", "bar.kawasaki.jp", "foo.bar.kawasaki.jp"},
+		{"bar.kawasaki.jp", "bar.kawasaki.jp", "bar.kawasaki.jp"},
+		{"city.kawasaki.jp", "kawasaki.jp", "city.kawasaki.jp"},
+		{"www.city.kawasaki.jp", "kawasaki.jp", "city.kawasaki.jp"},
+
+		// Private section rules
+		{"user.github.io", "github.io", "user.github.io"},
+		{"www.user.github.io", "github.io", "user.github.io"},
+		{"github.io", "github.io", "github.io"},
+
+		// IDN rules are matched as A-labels: 公司.cn and рф
+		{"example.xn--55qx5d.cn", "xn--55qx5d.cn", "example.xn--55qx5d.cn"},
+		{"mail.xn--e1afmkfd.xn--p1ai", "xn--p1ai", "xn--e1afmkfd.xn--p1ai"},
+
+		// Unlisted top-level domains are public suffixes
+		{"mail.example.invalidtld", "invalidtld", "example.invalidtld"},
+	}
+
+	list := Embedded()
+	for _, tt := range tests {
+		if got := list.PublicSuffix(tt.domain); got != tt.suffix {
+			t.Errorf("PublicSuffix(%q) = %q, want %q", tt.domain, got, tt.suffix)
+		}
+		if got := list.OrganizationalDomain(tt.domain); got != tt.org {
+			t.Errorf(
+				"OrganizationalDomain(%q) = %q, want %q",
+				tt.domain,
+				got,
+				tt.org,
+			)
+		}
+	}
+}
+
+func TestIsRegistered(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   bool
+	}{
+		{"example.com", true},
+		{"Mail.Example.co.uk.", true},
+		{"foo.bar.kawasaki.jp", true},
+		{"user.github.io", true},
+		{"com", false},
+		{"co.uk", false},
+		{"bar.kawasaki.jp", false},
+		{"example.invalidtld", false},
+		{"google", false},
+		{"1234567890", false},
+		{"192.0.2.1", false},
+		{"a..example.com", false},
+		{"example com", false},
+		{"dmarc@example.com", false},
+		{"", false},
+	}
+
+	list := Embedded()
+	for _, tt := range tests {
+		if got := list.IsRegistered(tt.domain); got != tt.want {
+			t.Errorf("IsRegistered(%q) = %v, want %v", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	list, err := Parse(strings.NewReader(`// comment
+example
+*.wild.example
+!ok.wild.example
+бизнес.example
+
+// ===BEGIN PRIVATE DOMAINS===
+Hosted.Example trailing text
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		domain string
+		org    string
+	}{
+		{"a.b.example", "b.example"},
+		{"a.b.wild.example", "a.b.wild.example"},
+		{"a.ok.wild.example", "ok.wild.example"},
+		{"a.site.hosted.example", "site.hosted.example"},
+		{"a.b.xn--90aifd0az.example", "b.xn--90aifd0az.example"},
+	}
+	for _, tt := range tests {
+		if got := list.OrganizationalDomain(tt.domain); got != tt.org {
+			t.Errorf(
+				"OrganizationalDomain(%q) = %q, want %q",
+				tt.domain,
+				got,
+				tt.org,
+			)
+		}
+	}
+
+	if _, err := Parse(strings.NewReader("// only a comment\n")); err == nil {
+		t.Error("Parse of a list without rules succeeded")
+	}
+	if _, err := Parse(strings.NewReader("bad\xff.example\n")); err == nil {
+		t.Error("Parse of a rule with invalid UTF-8 succeeded")
+	}
+}
+
+func TestSetDefault(t *testing.T) {
+	list, err := Parse(strings.NewReader("example.com\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	SetDefault(list)
+	defer SetDefault(nil)
+	if got := OrganizationalDomain("a.b.example.com"); got != "b.example.com" {
+		t.Errorf("with custom list: got %q, want b.example.com", got)
+	}
+
+	SetDefault(nil)
+	if got := OrganizationalDomain("a.b.example.com"); got != "example.com" {
+		t.Errorf("with embedded list: got %q, want example.com", got)
+	}
+}
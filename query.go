@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/huhndev/godmarc/storage"
+)
+
+// dateLayout is the format of the dates of query flags
+const dateLayout = "2006-01-02"
+
+// queryFlags defines the flags selecting aggregate report records on fs.
+// The returned function builds the query once fs is parsed.
+func queryFlags(fs *flag.FlagSet) func() (storage.RecordQuery, error) {
+	since := fs.String("since", "", "only reports beginning on or after this date (YYYY-MM-DD)")
+	until := fs.String("until", "", "only reports beginning before this date (YYYY-MM-DD)")
+	domain := fs.String("domain", "", "only records of this header From domain")
+	ip := fs.String("ip", "", "only records of this source IP address")
+	reporter := fs.String("reporter", "", "only reports of this reporting organization")
+
+	return func() (storage.RecordQuery, error) {
+		query := storage.RecordQuery{
+			Domain:   *domain,
+			SourceIP: *ip,
+			Reporter: *reporter,
+		}
+		var err error
+		if *since != "" {
+			if query.Begin, err = time.Parse(dateLayout, *since); err != nil {
+				return query, fmt.Errorf("invalid -since date: %w", err)
+			}
+		}
+		if *until != "" {
+			if query.End, err = time.Parse(dateLayout, *until); err != nil {
+				return query, fmt.Errorf("invalid -until date: %w", err)
+			}
+		}
+		return query, nil
+	}
+}
+
+// openBackend opens the database at path, or ~/.godmarc if path is empty.
+// The returned function closes it.
+func openBackend(path string, lenient bool) (storage.Backend, func(), error) {
+	if path != "" {
+		db, err := storage.OpenDB(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		db.Lenient = lenient
+		return db, func() { db.Close() }, nil
+	}
+
+	loader, err := storage.NewReportLoader()
+	if err != nil {
+		return nil, nil, err
+	}
+	loader.Lenient = lenient
+	return loader, func() {}, nil
+}
+
+// runList implements the list command, which prints a line per stored
+// aggregate report
+func runList(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	path := fs.String("db", "", "database file to list instead of ~/.godmarc")
+	lenient := fs.Bool("lenient", false, "keep the readable records of partially malformed reports")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godmarc list [flags]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	backend, closeBackend, err := openBackend(*path, *lenient)
+	if err != nil {
+		return err
+	}
+	defer closeBackend()
+
+	infos, err := backend.List()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tREPORTER\tREPORT ID\tDOMAIN\tBEGIN\tRECORDS")
+	for _, info := range infos {
+		fmt.Fprintf(
+			w,
+			"%s\t%s\t%s\t%s\t%s\t%d\n",
+			info.ID,
+			info.OrgName,
+			info.ReportID,
+			info.Domain,
+			info.DateRange.Begin.Format(dateLayout),
+			info.Records,
+		)
+	}
+	return w.Flush()
+}
+
+// runQuery implements the query command, which exports the aggregate
+// report records matching its flags as CSV
+func runQuery(args []string) error {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	path := fs.String("db", "", "database file to query instead of ~/.godmarc")
+	lenient := fs.Bool("lenient", false, "keep the readable records of partially malformed reports")
+	buildQuery := queryFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godmarc query [flags]")
+		fmt.Fprintln(fs.Output(), "The matching records are written to standard output as CSV.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	query, err := buildQuery()
+	if err != nil {
+		return err
+	}
+
+	backend, closeBackend, err := openBackend(*path, *lenient)
+	if err != nil {
+		return err
+	}
+	defer closeBackend()
+
+	results, err := backend.QueryRecords(query)
+	if err != nil {
+		return err
+	}
+
+	w := csv.NewWriter(os.Stdout)
+	w.Write([]string{
+		"id", "reporter", "report_id", "begin", "end", "source_ip",
+		"count", "header_from", "disposition", "dkim", "spf",
+	})
+	for _, result := range results {
+		row := result.Record.Row
+		w.Write([]string{
+			result.Report.ID,
+			result.Report.OrgName,
+			result.Report.ReportID,
+			result.Report.DateRange.Begin.Format(time.RFC3339),
+			result.Report.DateRange.End.Format(time.RFC3339),
+			row.SourceIP,
+			strconv.Itoa(row.Count),
+			result.Record.Identifiers.HeaderFrom,
+			row.PolicyEvaluated.Disposition,
+			row.PolicyEvaluated.DKIM,
+			row.PolicyEvaluated.SPF,
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// runDelete implements the delete command, which removes stored reports
+// by the IDs the list command prints
+func runDelete(args []string) error {
+	fs := flag.NewFlagSet("delete", flag.ContinueOnError)
+	path := fs.String("db", "", "database file to delete from instead of ~/.godmarc")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godmarc delete [flags] id...")
+		fmt.Fprintln(fs.Output(), "In ~/.godmarc the ID is a file name; all reports in the file are deleted.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("no report ID given")
+	}
+
+	backend, closeBackend, err := openBackend(*path, false)
+	if err != nil {
+		return err
+	}
+	defer closeBackend()
+
+	for _, id := range fs.Args() {
+		if err := backend.DeleteReport(id); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted %s\n", id)
+	}
+	return nil
+}
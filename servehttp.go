@@ -0,0 +1,49 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/huhndev/godmarc/ingest"
+	"github.com/huhndev/godmarc/storage"
+)
+
+// runServeHTTP implements the serve-http command, which accepts report
+// uploads and stores them in ~/.godmarc
+func runServeHTTP(args []string) error {
+	fs := flag.NewFlagSet("serve-http", flag.ContinueOnError)
+	addr := fs.String("addr", "127.0.0.1:8025", "address to listen on")
+	maxSize := fs.Int64("max-size", ingest.DefaultMaxUploadBytes, "maximum upload size in bytes")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godmarc serve-http [flags]")
+		fmt.Fprintln(fs.Output(), "If GODMARC_HTTP_TOKEN is set, uploads must send it as a bearer token.")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	loader, err := storage.NewReportLoader()
+	if err != nil {
+		return err
+	}
+
+	logger := log.New(os.Stderr, "", log.LstdFlags)
+	receiver := ingest.NewHTTPReceiver(ingest.HTTPConfig{
+		Addr:           *addr,
+		MaxUploadBytes: *maxSize,
+		Token:          os.Getenv("GODMARC_HTTP_TOKEN"),
+		Log:            logger,
+	}, loader)
+
+	logger.Printf("accepting report uploads on http://%s/reports", *addr)
+
+	return receiver.ListenAndServe()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/huhndev/godmarc/ingest"
+	"github.com/huhndev/godmarc/storage"
+)
+
+// runServeSMTP implements the serve-smtp command, which receives rua mail
+// and stores the reports in ~/.godmarc
+func runServeSMTP(args []string) error {
+	fs := flag.NewFlagSet("serve-smtp", flag.ContinueOnError)
+	addr := fs.String("addr", ":2525", "address to listen on")
+	domain := fs.String("domain", "localhost", "host name announced to clients")
+	maxSize := fs.Int64("max-size", ingest.DefaultMaxMessageBytes, "maximum message size in bytes")
+	maxRcpt := fs.Int("max-recipients", 10, "maximum recipients per message")
+	allow := fs.String("allow", "", "comma-separated accepted recipients, @domain accepts a whole domain")
+	certFile := fs.String("tls-cert", "", "certificate file, enables STARTTLS together with -tls-key")
+	keyFile := fs.String("tls-key", "", "private key file for STARTTLS")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: godmarc serve-smtp [flags]")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	config := ingest.SMTPConfig{
+		Addr:            *addr,
+		Domain:          *domain,
+		MaxMessageBytes: *maxSize,
+		MaxRecipients:   *maxRcpt,
+		Log:             log.New(os.Stderr, "", log.LstdFlags),
+	}
+
+	for _, rcpt := range strings.Split(*allow, ",") {
+		if rcpt = strings.TrimSpace(rcpt); rcpt != "" {
+			config.AllowedRecipients = append(config.AllowedRecipients, rcpt)
+		}
+	}
+
+	if *certFile != "" || *keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+		if err != nil {
+			return fmt.Errorf("could not load TLS certificate: %w", err)
+		}
+		config.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	}
+
+	loader, err := storage.NewReportLoader()
+	if err != nil {
+		return err
+	}
+
+	receiver := ingest.NewSMTPReceiver(config, loader)
+	config.Log.Printf("listening for rua mail on %s", *addr)
+
+	return receiver.ListenAndServe()
+}
@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/huhndev/godmarc/model"
+)
+
+var (
+	// ErrReportNotFound is returned when deleting a report that is not
+	// stored
+	ErrReportNotFound = errors.New("report not found")
+	// ErrDuplicateReport is returned when storing an aggregate report
+	// whose reporter and report ID are already stored
+	ErrDuplicateReport = errors.New("report already stored")
+)
+
+// Backend stores reports. The directory loader (ReportLoader) and the
+// database (DB) implement it.
+type Backend interface {
+	// Name describes where the reports are stored, for display
+	Name() string
+	// List returns a summary of every stored aggregate report
+	List() ([]ReportInfo, error)
+	// Load loads the stored reports, calling progress, if not nil, as it
+	// goes. Aggregate reports only hold the records matching query, and
+	// those without any are left out.
+	Load(query RecordQuery, progress func(LoadProgress)) (ReportSet, error)
+	// SaveReport stores a report file (xml, gz, zip or eml) and returns
+	// the ID it is stored under, see ReportInfo.ID
+	SaveReport(name string, data []byte) (string, error)
+	// DeleteReport removes the reports stored under id, see ReportInfo.ID
+	DeleteReport(id string) error
+	// QueryRecords returns the aggregate report records matching query
+	QueryRecords(query RecordQuery) ([]RecordResult, error)
+}
+
+// ReportInfo summarizes a stored aggregate report
+type ReportInfo struct {
+	// ID identifies the report for DeleteReport. In a directory it is the
+	// file the report was loaded from, which may hold several reports. In
+	// a database it is the report's number; SaveReport joins the numbers
+	// of the reports of one file with commas.
+	ID        string
+	OrgName   string
+	ReportID  string
+	Domain    string
+	DateRange model.DateRange
+	Records   int
+}
+
+// RecordQuery selects aggregate report records. Empty fields match
+// everything.
+type RecordQuery struct {
+	// Begin and End select reports whose date range begins at or after
+	// Begin and before End
+	Begin time.Time
+	End   time.Time
+	// Domain matches the header From domain of a record, or the policy
+	// domain of its report if the record has none
+	Domain string
+	// SourceIP matches the sending IP address of a record. IPv6
+	// addresses match however they are written.
+	SourceIP string
+	// Reporter matches the organization that sent the report
+	Reporter string
+}
+
+// RecordResult is a record matching a RecordQuery and its report
+type RecordResult struct {
+	Report ReportInfo
+	Record model.Record
+}
+
+// reportInfo summarizes a report stored under id
+func reportInfo(id string, report model.DMARCReport) ReportInfo {
+	return ReportInfo{
+		ID:        id,
+		OrgName:   report.ReportMetadata.OrgName,
+		ReportID:  report.ReportMetadata.ReportID,
+		Domain:    report.PolicyPublished.Domain,
+		DateRange: report.ReportMetadata.DateRange,
+		Records:   len(report.Records),
+	}
+}
+
+// selectsAll reports whether the query matches every record
+func (q RecordQuery) selectsAll() bool {
+	return q.Begin.IsZero() && q.End.IsZero() && q.Domain == "" &&
+		q.SourceIP == "" && q.Reporter == ""
+}
+
+// filter returns reports with only the records matching the query,
+// leaving out reports without any
+func (q RecordQuery) filter(reports []model.DMARCReport) []model.DMARCReport {
+	if q.selectsAll() {
+		return reports
+	}
+
+	var filtered []model.DMARCReport
+	for _, report := range reports {
+		if !q.matchesReport(report) {
+			continue
+		}
+		var records []model.Record
+		for _, record := range report.Records {
+			if q.matchesRecord(report, record) {
+				records = append(records, record)
+			}
+		}
+		if len(records) > 0 {
+			report.Records = records
+			filtered = append(filtered, report)
+		}
+	}
+	return filtered
+}
+
+// matchesReport reports whether the query may match records of report
+func (q RecordQuery) matchesReport(report model.DMARCReport) bool {
+	begin := report.ReportMetadata.DateRange.Begin
+	if !q.Begin.IsZero() && begin.Before(q.Begin) {
+		return false
+	}
+	if !q.End.IsZero() && !begin.Before(q.End) {
+		return false
+	}
+	if q.Reporter != "" &&
+		!strings.EqualFold(report.ReportMetadata.OrgName, q.Reporter) {
+		return false
+	}
+	return true
+}
+
+// matchesRecord reports whether the query matches a record of a report
+// it matches
+func (q RecordQuery) matchesRecord(
+	report model.DMARCReport,
+	record model.Record,
+) bool {
+	if q.SourceIP != "" {
+		ip := model.NormalizeIP(record.Row.SourceIP)
+		if ip != model.NormalizeIP(q.SourceIP) {
+			return false
+		}
+	}
+	if q.Domain != "" &&
+		!strings.EqualFold(recordDomain(report, record), q.Domain) {
+		return false
+	}
+	return true
+}
+
+// recordDomain returns the domain a record is indexed and queried by
+func recordDomain(report model.DMARCReport, record model.Record) string {
+	if record.Identifiers.HeaderFrom != "" {
+		return record.Identifiers.HeaderFrom
+	}
+	return report.PolicyPublished.Domain
+}
+
+// Name implements Backend
+func (l *ReportLoader) Name() string {
+	return l.ConfigDir
+}
+
+// Load implements Backend. It parses every file in the directory and
+// filters the records in memory.
+func (l *ReportLoader) Load(
+	query RecordQuery,
+	progress func(LoadProgress),
+) (ReportSet, error) {
+	loader := *l
+	loader.Progress = progress
+	set, err := loader.LoadAll()
+	set.DMARC = query.filter(set.DMARC)
+	return set, err
+}
+
+// List implements Backend. It parses every file in the directory.
+func (l *ReportLoader) List() ([]ReportInfo, error) {
+	reports, err := l.LoadReports()
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]ReportInfo, 0, len(reports))
+	for _, report := range reports {
+		infos = append(infos, reportInfo(report.Source, report))
+	}
+	return infos, nil
+}
+
+// DeleteReport implements Backend. It removes the file id, with all the
+// reports in it.
+func (l *ReportLoader) DeleteReport(id string) error {
+	path := filepath.Join(l.ConfigDir, id)
+	rel, err := filepath.Rel(l.ConfigDir, path)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("%w: %s", ErrReportNotFound, id)
+	}
+
+	if err := os.Remove(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("%w: %s", ErrReportNotFound, id)
+		}
+		return fmt.Errorf("could not delete report %s: %w", id, err)
+	}
+	return nil
+}
+
+// QueryRecords implements Backend. It parses every file in the directory
+// and filters the records in memory.
+func (l *ReportLoader) QueryRecords(query RecordQuery) ([]RecordResult, error) {
+	reports, err := l.LoadReports()
+	if err != nil {
+		return nil, err
+	}
+
+	var results []RecordResult
+	for _, report := range reports {
+		if !query.matchesReport(report) {
+			continue
+		}
+		info := reportInfo(report.Source, report)
+		for _, record := range report.Records {
+			if query.matchesRecord(report, record) {
+				results = append(results, RecordResult{
+					Report: info,
+					Record: record,
+				})
+			}
+		}
+	}
+	return results, nil
+}
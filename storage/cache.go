@@ -0,0 +1,177 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/gob"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/huhndev/godmarc/model"
+	"github.com/huhndev/godmarc/parser"
+)
+
+// cacheVersion is stored in the cache file and must be increased whenever
+// the cached types change in a way gob cannot bridge
+const cacheVersion = 2
+
+// reportCache holds the parsed reports of files that loaded completely, so
+// they are not parsed again while they stay unchanged. The cache is only an
+// optimization: a cache file that cannot be read or written is ignored.
+type reportCache struct {
+	// Version, ConfigDir, Limits and Lenient describe the load the
+	// entries come from. Entries of a different load are not used.
+	Version   int
+	ConfigDir string
+	Limits    parser.Limits
+	Lenient   bool
+	// Entries are keyed by file path
+	Entries map[string]cacheEntry
+}
+
+// cacheEntry is a file and the reports that were loaded from it
+type cacheEntry struct {
+	Key      fileKey
+	Result   ParseResult
+	DMARC    []model.DMARCReport
+	Forensic []model.ForensicReport
+	TLS      []model.TLSReport
+}
+
+// fileKey identifies the content of a file
+type fileKey struct {
+	Size int64
+	// ModTime is in Unix nanoseconds, which survive encoding unchanged
+	ModTime int64
+	Hash    [sha256.Size]byte
+}
+
+// newReportCache creates an empty cache for loads of l
+func (l *ReportLoader) newReportCache() *reportCache {
+	return &reportCache{
+		Version:   cacheVersion,
+		ConfigDir: l.ConfigDir,
+		Limits:    l.newLoadState().limits(),
+		Lenient:   l.Lenient,
+		Entries:   make(map[string]cacheEntry),
+	}
+}
+
+// readCache reads the cache of l from disk. It returns an empty cache if
+// there is none or it was written for a different load.
+func (l *ReportLoader) readCache() *reportCache {
+	cache := l.newReportCache()
+
+	f, err := os.Open(l.CacheFile)
+	if err != nil {
+		return cache
+	}
+	defer f.Close()
+
+	var stored reportCache
+	if err := gob.NewDecoder(f).Decode(&stored); err != nil {
+		return cache
+	}
+	if stored.Version != cache.Version ||
+		stored.ConfigDir != cache.ConfigDir ||
+		stored.Limits != cache.Limits ||
+		stored.Lenient != cache.Lenient {
+		return cache
+	}
+
+	cache.Entries = stored.Entries
+	return cache
+}
+
+// writeCache replaces the cache of l on disk
+func (l *ReportLoader) writeCache(cache *reportCache) {
+	dir := filepath.Dir(l.CacheFile)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return
+	}
+
+	// Write to a temporary file first so readers never see a partial cache
+	tmp, err := os.CreateTemp(dir, ".reports-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(cache); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+
+	_ = os.Rename(tmp.Name(), l.CacheFile)
+}
+
+// lookup returns the entry of a file loaded earlier if its content is
+// unchanged. The modification time is not compared, so a file that was
+// only touched is still found.
+func (c *reportCache) lookup(path string, key fileKey) (cacheEntry, bool) {
+	entry, ok := c.Entries[path]
+	if !ok || entry.Key.Size != key.Size || entry.Key.Hash != key.Hash {
+		return cacheEntry{}, false
+	}
+	entry.Key = key
+	return entry, true
+}
+
+// state returns the load state of the cached file
+func (e cacheEntry) state() *loadState {
+	return &loadState{
+		reports:  e.DMARC,
+		forensic: e.Forensic,
+		tls:      e.TLS,
+		results:  []ParseResult{e.Result},
+	}
+}
+
+// entry returns the cache entry for a file loaded into state, or false if
+// the file did not load completely and must be parsed again next time
+func (s *loadState) entry(key fileKey) (cacheEntry, bool) {
+	if len(s.results) != 1 || s.results[0].Status != StatusLoaded {
+		return cacheEntry{}, false
+	}
+
+	return cacheEntry{
+		Key:      key,
+		Result:   s.results[0],
+		DMARC:    s.reports,
+		Forensic: s.forensic,
+		TLS:      s.tls,
+	}, true
+}
+
+// fileKey identifies the current content of the file at path. The file is
+// only hashed if its size or modification time differ from its entry.
+func (c *reportCache) fileKey(path string) (fileKey, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileKey{}, err
+	}
+
+	key := fileKey{Size: info.Size(), ModTime: info.ModTime().UnixNano()}
+	if entry, ok := c.Entries[path]; ok &&
+		entry.Key.Size == key.Size &&
+		entry.Key.ModTime == key.ModTime {
+		key.Hash = entry.Key.Hash
+		return key, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return fileKey{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fileKey{}, err
+	}
+	h.Sum(key.Hash[:0])
+	return key, nil
+}
@@ -0,0 +1,774 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/huhndev/godmarc/model"
+	"github.com/huhndev/godmarc/parser"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Buckets of the database. Aggregate reports are split into a header in
+// reportsBucket, keyed by report number, and their records in
+// recordsBucket, keyed by report number and position. Index keys are the
+// indexed value, a zero byte and the key of the report or record.
+// reportIDIndex holds the model.DuplicateKey of each report once, so a
+// report is only stored once.
+var (
+	reportsBucket  = []byte("reports")
+	recordsBucket  = []byte("records")
+	forensicBucket = []byte("forensic")
+	tlsBucket      = []byte("tls")
+	dateIndex      = []byte("index_date")
+	reporterIndex  = []byte("index_reporter")
+	domainIndex    = []byte("index_domain")
+	sourceIPIndex  = []byte("index_source_ip")
+	reportIDIndex  = []byte("index_report_id")
+)
+
+// dbBuckets lists every bucket of the database
+var dbBuckets = [][]byte{
+	reportsBucket,
+	recordsBucket,
+	forensicBucket,
+	tlsBucket,
+	dateIndex,
+	reporterIndex,
+	domainIndex,
+	sourceIPIndex,
+	reportIDIndex,
+}
+
+// DB stores reports in a single database file. Aggregate reports are
+// stored normalized and indexed by date, reporter, domain and source IP,
+// so queries only read the matching records.
+type DB struct {
+	// Limits and Lenient configure how SaveReport parses reports, see
+	// ReportLoader
+	Limits  parser.Limits
+	Lenient bool
+
+	db   *bolt.DB
+	path string
+}
+
+// dbKey is a key in a bucket
+type dbKey struct {
+	bucket []byte
+	key    []byte
+}
+
+// dbReport is the header of a stored aggregate report
+type dbReport struct {
+	// Report is the report without its records
+	Report  model.DMARCReport
+	Records int
+}
+
+// dbTLSReport is a stored TLS report. Its provenance is not part of the
+// report's JSON.
+type dbTLSReport struct {
+	Report     model.TLSReport
+	Provenance model.Provenance
+}
+
+// OpenDB opens the database file at path, creating it if it does not
+// exist. Only one process can open the file at a time.
+func OpenDB(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("could not open database %s: %w", path, err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		// Databases created before the report ID index need it built
+		buildReportIDs := tx.Bucket(reportIDIndex) == nil
+		for _, name := range dbBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		if buildReportIDs {
+			return indexReportIDs(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("could not set up database %s: %w", path, err)
+	}
+
+	return &DB{Limits: parser.DefaultLimits, db: db, path: path}, nil
+}
+
+// Close closes the database file
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// Name implements Backend
+func (d *DB) Name() string {
+	return d.path
+}
+
+// Store adds parsed reports to the database, e.g. to import the reports of
+// a directory. The Source of the stored aggregate reports is their ID.
+// Aggregate reports already stored are skipped; Store returns how many.
+func (d *DB) Store(set ReportSet) (int, error) {
+	var duplicates int
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		duplicates = 0
+		for _, report := range set.DMARC {
+			_, err := putReport(tx, report)
+			if errors.Is(err, ErrDuplicateReport) {
+				duplicates++
+				continue
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return putOtherReports(tx, set.Forensic, set.TLS)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not store reports: %w", err)
+	}
+	return duplicates, nil
+}
+
+// SaveReport implements Backend. It parses the file, stores the reports in
+// it and returns the IDs of its aggregate reports joined by commas, or an
+// empty ID if it only holds failure and TLS reports. It fails if the file
+// contains no reports, or only aggregate reports that are already stored.
+func (d *DB) SaveReport(name string, data []byte) (string, error) {
+	loader := ReportLoader{Limits: d.Limits, Lenient: d.Lenient}
+	state := loader.newLoadState()
+	loadData(state, name, data)
+
+	if state.reportCount() == 0 {
+		if len(state.parseErrors) > 0 {
+			return "", errors.Join(state.parseErrors...)
+		}
+		return "", fmt.Errorf("%w: %s", ErrNoReportsInFile, name)
+	}
+
+	var ids []string
+	err := d.db.Update(func(tx *bolt.Tx) error {
+		ids = nil
+		var duplicate error
+		for _, report := range state.reports {
+			id, err := putReport(tx, report)
+			if errors.Is(err, ErrDuplicateReport) {
+				duplicate = err
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			ids = append(ids, id)
+		}
+		if len(ids)+len(state.forensic)+len(state.tls) == 0 {
+			return duplicate
+		}
+		return putOtherReports(tx, state.forensic, state.tls)
+	})
+	if err != nil {
+		return "", fmt.Errorf("could not store report %s: %w", name, err)
+	}
+
+	return strings.Join(ids, ","), nil
+}
+
+// Load implements Backend. The reports are loaded in the order they were
+// stored. If query selects some of the records, only those are read,
+// through the indexes.
+func (d *DB) Load(
+	query RecordQuery,
+	progress func(LoadProgress),
+) (ReportSet, error) {
+	var set ReportSet
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		var err error
+		if query.selectsAll() {
+			set.DMARC, err = loadReports(tx, progress)
+		} else {
+			set.DMARC, err = loadMatchingReports(tx, query, progress)
+		}
+		if err != nil {
+			return err
+		}
+
+		err = tx.Bucket(forensicBucket).ForEach(func(_, value []byte) error {
+			var report model.ForensicReport
+			if err := decodeValue(value, &report); err != nil {
+				return err
+			}
+			set.Forensic = append(set.Forensic, report)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return tx.Bucket(tlsBucket).ForEach(func(_, value []byte) error {
+			var stored dbTLSReport
+			if err := decodeValue(value, &stored); err != nil {
+				return err
+			}
+			report := stored.Report
+			report.Provenance = stored.Provenance
+			set.TLS = append(set.TLS, report)
+			return nil
+		})
+	})
+	if err != nil {
+		return ReportSet{}, fmt.Errorf(
+			"could not read database %s: %w",
+			d.path,
+			err,
+		)
+	}
+
+	if len(set.DMARC)+len(set.Forensic)+len(set.TLS) == 0 {
+		return set, fmt.Errorf("%w in %s", ErrNoReports, d.path)
+	}
+	return set, nil
+}
+
+// loadReports reads every stored aggregate report
+func loadReports(
+	tx *bolt.Tx,
+	progress func(LoadProgress),
+) ([]model.DMARCReport, error) {
+	var loaded []model.DMARCReport
+	reports := tx.Bucket(reportsBucket)
+	records := tx.Bucket(recordsBucket)
+
+	status := LoadProgress{Total: reports.Stats().KeyN}
+	err := reports.ForEach(func(key, value []byte) error {
+		var header dbReport
+		if err := decodeValue(value, &header); err != nil {
+			return err
+		}
+
+		report := header.Report
+		report.Records = make([]model.Record, 0, header.Records)
+		c := records.Cursor()
+		for k, v := c.Seek(key); hasPrefix(k, key); k, v = c.Next() {
+			var record model.Record
+			if err := decodeValue(v, &record); err != nil {
+				return err
+			}
+			report.Records = append(report.Records, record)
+		}
+		loaded = append(loaded, report)
+
+		status.Done++
+		if progress != nil {
+			progress(status)
+		}
+		return nil
+	})
+	return loaded, err
+}
+
+// loadMatchingReports reads the aggregate reports with records matching
+// query, holding only those records
+func loadMatchingReports(
+	tx *bolt.Tx,
+	query RecordQuery,
+	progress func(LoadProgress),
+) ([]model.DMARCReport, error) {
+	matches, err := matchingRecords(tx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	// The records of a report are consecutive
+	var loaded []model.DMARCReport
+	var last *dbReport
+	for _, match := range matches {
+		if match.header != last {
+			report := match.header.Report
+			report.Records = nil
+			loaded = append(loaded, report)
+			last = match.header
+		}
+		report := &loaded[len(loaded)-1]
+		report.Records = append(report.Records, match.record)
+	}
+
+	if progress != nil {
+		progress(LoadProgress{Total: len(loaded), Done: len(loaded)})
+	}
+	return loaded, nil
+}
+
+// List implements Backend. It only reads the report headers.
+func (d *DB) List() ([]ReportInfo, error) {
+	var infos []ReportInfo
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(reportsBucket).ForEach(func(key, value []byte) error {
+			var header dbReport
+			if err := decodeValue(value, &header); err != nil {
+				return err
+			}
+			info := reportInfo(header.Report.Source, header.Report)
+			info.Records = header.Records
+			infos = append(infos, info)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read database %s: %w", d.path, err)
+	}
+	return infos, nil
+}
+
+// DeleteReport implements Backend. It removes the report with its records
+// and index entries. id may list several reports separated by commas, as
+// SaveReport returns them.
+func (d *DB) DeleteReport(id string) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		for _, n := range strings.Split(id, ",") {
+			if err := deleteReport(tx, n); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// deleteReport removes the report with ID id
+func deleteReport(tx *bolt.Tx, id string) error {
+	n, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrReportNotFound, id)
+	}
+	key := reportKey(n)
+
+	reports := tx.Bucket(reportsBucket)
+	value := reports.Get(key)
+	if value == nil {
+		return fmt.Errorf("%w: %s", ErrReportNotFound, id)
+	}
+
+	var header dbReport
+	if err := decodeValue(value, &header); err != nil {
+		return err
+	}
+	report := header.Report
+
+	// Collect the keys first, deleting moves the cursor
+	deletes := []dbKey{
+		{dateIndex, dateIndexKey(report, key)},
+		{reporterIndex, reporterIndexKey(report, key)},
+	}
+	c := tx.Bucket(recordsBucket).Cursor()
+	for k, v := c.Seek(key); hasPrefix(k, key); k, v = c.Next() {
+		var record model.Record
+		if err := decodeValue(v, &record); err != nil {
+			return err
+		}
+		report.Records = append(report.Records, record)
+		recordKey := bytes.Clone(k)
+		deletes = append(deletes,
+			dbKey{recordsBucket, recordKey},
+			dbKey{domainIndex, domainIndexKey(report, record, recordKey)},
+			dbKey{sourceIPIndex, sourceIPIndexKey(record, recordKey)},
+		)
+	}
+	// A report without an ID is indexed by a hash of its records
+	deletes = append(deletes,
+		dbKey{reportIDIndex, reportIDIndexKey(report, key)},
+	)
+
+	for _, del := range deletes {
+		if err := tx.Bucket(del.bucket).Delete(del.key); err != nil {
+			return err
+		}
+	}
+	return reports.Delete(key)
+}
+
+// QueryRecords implements Backend. It looks up the records through the
+// most selective index for the query: source IP, domain, reporter, then
+// date. Results are in the order the reports were stored.
+func (d *DB) QueryRecords(query RecordQuery) ([]RecordResult, error) {
+	var results []RecordResult
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		matches, err := matchingRecords(tx, query)
+		if err != nil {
+			return err
+		}
+
+		for _, match := range matches {
+			report := match.header.Report
+			info := reportInfo(report.Source, report)
+			info.Records = match.header.Records
+			results = append(results, RecordResult{
+				Report: info,
+				Record: match.record,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(
+			"could not query database %s: %w",
+			d.path,
+			err,
+		)
+	}
+	return results, nil
+}
+
+// dbRecord is a stored record and the header of its report
+type dbRecord struct {
+	header *dbReport
+	record model.Record
+}
+
+// matchingRecords returns the records matching query in the order they
+// were stored. Records of the same report share its header.
+func matchingRecords(tx *bolt.Tx, query RecordQuery) ([]dbRecord, error) {
+	keys, err := queryRecordKeys(tx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	reports := tx.Bucket(reportsBucket)
+	records := tx.Bucket(recordsBucket)
+	headers := make(map[string]*dbReport)
+
+	var matches []dbRecord
+	for _, key := range keys {
+		reportKey := string(key[:8])
+		header, ok := headers[reportKey]
+		if !ok {
+			header = &dbReport{}
+			if err := decodeValue(reports.Get(key[:8]), header); err != nil {
+				return nil, err
+			}
+			headers[reportKey] = header
+		}
+		if !query.matchesReport(header.Report) {
+			continue
+		}
+
+		var record model.Record
+		if err := decodeValue(records.Get(key), &record); err != nil {
+			return nil, err
+		}
+		if !query.matchesRecord(header.Report, record) {
+			continue
+		}
+
+		matches = append(matches, dbRecord{header: header, record: record})
+	}
+	return matches, nil
+}
+
+// queryRecordKeys returns the sorted keys of the records that may match
+// query, narrowed down by one index
+func queryRecordKeys(tx *bolt.Tx, query RecordQuery) ([][]byte, error) {
+	var keys [][]byte
+
+	switch {
+	case query.SourceIP != "":
+		keys = scanIndex(
+			tx.Bucket(sourceIPIndex),
+			model.NormalizeIP(query.SourceIP),
+		)
+	case query.Domain != "":
+		keys = scanIndex(
+			tx.Bucket(domainIndex),
+			strings.ToLower(query.Domain),
+		)
+	default:
+		var reportKeys [][]byte
+		switch {
+		case query.Reporter != "":
+			reportKeys = scanIndex(
+				tx.Bucket(reporterIndex),
+				strings.ToLower(query.Reporter),
+			)
+		case !query.Begin.IsZero() || !query.End.IsZero():
+			reportKeys = scanDateIndex(
+				tx.Bucket(dateIndex),
+				query.Begin,
+				query.End,
+			)
+		default:
+			err := tx.Bucket(reportsBucket).ForEach(func(key, _ []byte) error {
+				reportKeys = append(reportKeys, bytes.Clone(key))
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		c := tx.Bucket(recordsBucket).Cursor()
+		for _, reportKey := range reportKeys {
+			for k, _ := c.Seek(reportKey); hasPrefix(k, reportKey); k, _ = c.Next() {
+				keys = append(keys, bytes.Clone(k))
+			}
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+	return keys, nil
+}
+
+// scanIndex returns the report or record keys indexed under value
+func scanIndex(index *bolt.Bucket, value string) [][]byte {
+	prefix := indexPrefix(value)
+
+	var keys [][]byte
+	c := index.Cursor()
+	for k, _ := c.Seek(prefix); hasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, bytes.Clone(k[len(prefix):]))
+	}
+	return keys
+}
+
+// scanDateIndex returns the keys of the reports beginning at or after
+// begin and before end, which may be zero
+func scanDateIndex(index *bolt.Bucket, begin, end time.Time) [][]byte {
+	var keys [][]byte
+	c := index.Cursor()
+	for k, _ := c.Seek(timeKey(begin)); k != nil; k, _ = c.Next() {
+		if !end.IsZero() && bytes.Compare(k[:8], timeKey(end)) >= 0 {
+			break
+		}
+		keys = append(keys, bytes.Clone(k[8:]))
+	}
+	return keys
+}
+
+// putReport stores an aggregate report with its records and index
+// entries, and returns its ID. It returns ErrDuplicateReport if a report
+// of the same reporter and report ID is stored.
+func putReport(tx *bolt.Tx, report model.DMARCReport) (string, error) {
+	reportID := reportIDValue(report)
+	if keys := scanIndex(tx.Bucket(reportIDIndex), reportID); len(keys) > 0 {
+		return "", fmt.Errorf(
+			"%w: %s from %s",
+			ErrDuplicateReport,
+			report.ReportMetadata.ReportID,
+			report.ReportMetadata.OrgName,
+		)
+	}
+
+	reports := tx.Bucket(reportsBucket)
+	n, err := reports.NextSequence()
+	if err != nil {
+		return "", err
+	}
+	key := reportKey(n)
+	id := strconv.FormatUint(n, 10)
+
+	records := report.Records
+	report.Records = nil
+	report.Source = id
+
+	value, err := encodeValue(dbReport{
+		Report:  report,
+		Records: len(records),
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := reports.Put(key, value); err != nil {
+		return "", err
+	}
+
+	puts := []dbKey{
+		{dateIndex, dateIndexKey(report, key)},
+		{reporterIndex, reporterIndexKey(report, key)},
+		{reportIDIndex, indexKey(reportID, key)},
+	}
+	for i, record := range records {
+		recordKey := binary.BigEndian.AppendUint32(
+			bytes.Clone(key),
+			uint32(i),
+		)
+		value, err := encodeValue(record)
+		if err != nil {
+			return "", err
+		}
+		if err := tx.Bucket(recordsBucket).Put(recordKey, value); err != nil {
+			return "", err
+		}
+		puts = append(puts,
+			dbKey{domainIndex, domainIndexKey(report, record, recordKey)},
+			dbKey{sourceIPIndex, sourceIPIndexKey(record, recordKey)},
+		)
+	}
+
+	// bbolt inserts keys in ascending order much faster
+	sort.Slice(puts, func(i, j int) bool {
+		if c := bytes.Compare(puts[i].bucket, puts[j].bucket); c != 0 {
+			return c < 0
+		}
+		return bytes.Compare(puts[i].key, puts[j].key) < 0
+	})
+	for _, put := range puts {
+		if err := tx.Bucket(put.bucket).Put(put.key, nil); err != nil {
+			return "", err
+		}
+	}
+	return id, nil
+}
+
+// indexReportIDs adds the stored aggregate reports to the report ID index
+func indexReportIDs(tx *bolt.Tx) error {
+	index := tx.Bucket(reportIDIndex)
+	records := tx.Bucket(recordsBucket)
+	return tx.Bucket(reportsBucket).ForEach(func(key, value []byte) error {
+		var header dbReport
+		if err := decodeValue(value, &header); err != nil {
+			return err
+		}
+
+		// The key of a report without an ID covers its records
+		report := header.Report
+		c := records.Cursor()
+		for k, v := c.Seek(key); hasPrefix(k, key); k, v = c.Next() {
+			var record model.Record
+			if err := decodeValue(v, &record); err != nil {
+				return err
+			}
+			report.Records = append(report.Records, record)
+		}
+		return index.Put(reportIDIndexKey(report, key), nil)
+	})
+}
+
+// putOtherReports stores failure and TLS reports
+func putOtherReports(
+	tx *bolt.Tx,
+	forensic []model.ForensicReport,
+	tls []model.TLSReport,
+) error {
+	for _, report := range forensic {
+		if err := putValue(tx.Bucket(forensicBucket), report); err != nil {
+			return err
+		}
+	}
+	for _, report := range tls {
+		stored := dbTLSReport{Report: report, Provenance: report.Provenance}
+		if err := putValue(tx.Bucket(tlsBucket), stored); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putValue stores a value under the next key of bucket
+func putValue(bucket *bolt.Bucket, v any) error {
+	n, err := bucket.NextSequence()
+	if err != nil {
+		return err
+	}
+	value, err := encodeValue(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(reportKey(n), value)
+}
+
+// reportKey returns the key of the report numbered n, which sorts in the
+// order reports were stored
+func reportKey(n uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, n)
+}
+
+// timeKey returns a key that sorts in time order. Times before 1970 sort
+// first.
+func timeKey(t time.Time) []byte {
+	var seconds uint64
+	if t.Unix() > 0 {
+		seconds = uint64(t.Unix())
+	}
+	return binary.BigEndian.AppendUint64(nil, seconds)
+}
+
+// indexPrefix returns the prefix of the index keys for value
+func indexPrefix(value string) []byte {
+	return append([]byte(value), 0)
+}
+
+// indexKey returns the index key of value for the report or record key
+func indexKey(value string, key []byte) []byte {
+	return append(indexPrefix(value), key...)
+}
+
+// dateIndexKey returns the date index key of a report. Its time is fixed
+// length, so it needs no separator.
+func dateIndexKey(report model.DMARCReport, key []byte) []byte {
+	return append(timeKey(report.ReportMetadata.DateRange.Begin), key...)
+}
+
+// reporterIndexKey returns the reporter index key of a report
+func reporterIndexKey(report model.DMARCReport, key []byte) []byte {
+	return indexKey(strings.ToLower(report.ReportMetadata.OrgName), key)
+}
+
+// reportIDValue returns the value a report is indexed by in the report ID
+// index, its model.DuplicateKey, so the database stores the reports the
+// TUI counts once only once
+func reportIDValue(report model.DMARCReport) string {
+	return report.DuplicateKey()
+}
+
+// reportIDIndexKey returns the report ID index key of a report
+func reportIDIndexKey(report model.DMARCReport, key []byte) []byte {
+	return indexKey(reportIDValue(report), key)
+}
+
+// domainIndexKey returns the domain index key of a record
+func domainIndexKey(
+	report model.DMARCReport,
+	record model.Record,
+	key []byte,
+) []byte {
+	return indexKey(strings.ToLower(recordDomain(report, record)), key)
+}
+
+// sourceIPIndexKey returns the source IP index key of a record. The
+// address is normalized, so IPv4-mapped and differently written IPv6
+// addresses are found under one key.
+func sourceIPIndexKey(record model.Record, key []byte) []byte {
+	return indexKey(model.NormalizeIP(record.Row.SourceIP), key)
+}
+
+// hasPrefix reports whether a cursor key is set and has prefix
+func hasPrefix(key, prefix []byte) bool {
+	return key != nil && bytes.HasPrefix(key, prefix)
+}
+
+// encodeValue encodes a value for the database. Values are JSON: gob
+// would repeat its type information in every one of them.
+func encodeValue(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// decodeValue decodes a value of the database into v
+func decodeValue(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,258 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/huhndev/godmarc/model"
+)
+
+// testReport returns an aggregate report from org beginning at begin with
+// a record per source IP, all from domain
+func testReport(
+	org, id string,
+	begin time.Time,
+	domain string,
+	ips ...string,
+) model.DMARCReport {
+	report := model.DMARCReport{
+		ReportMetadata: model.ReportMetadata{
+			OrgName:  org,
+			ReportID: id,
+			DateRange: model.DateRange{
+				Begin: begin,
+				End:   begin.Add(24 * time.Hour),
+			},
+		},
+		PolicyPublished: model.PolicyPublished{Domain: "example.com"},
+	}
+	for _, ip := range ips {
+		var record model.Record
+		record.Row.SourceIP = ip
+		record.Row.Count = 1
+		record.Identifiers.HeaderFrom = domain
+		report.Records = append(report.Records, record)
+	}
+	return report
+}
+
+// testXML returns the XML of an aggregate report with one record
+func testXML(org, id, ip string) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<feedback>
+  <report_metadata>
+    <org_name>%s</org_name>
+    <report_id>%s</report_id>
+    <date_range><begin>1700000000</begin><end>1700086399</end></date_range>
+  </report_metadata>
+  <policy_published><domain>example.com</domain><p>none</p></policy_published>
+  <record>
+    <row>
+      <source_ip>%s</source_ip><count>2</count>
+      <policy_evaluated><disposition>none</disposition><dkim>pass</dkim><spf>pass</spf></policy_evaluated>
+    </row>
+    <identifiers><header_from>example.com</header_from></identifiers>
+  </record>
+</feedback>
+`, org, id, ip))
+}
+
+// openTestDB opens a new database in a temporary directory
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := OpenDB(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+var day = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestDBRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+
+	set := ReportSet{DMARC: []model.DMARCReport{
+		testReport("Google", "a", day, "example.com", "192.0.2.1", "192.0.2.2"),
+		testReport("Yahoo", "b", day.AddDate(0, 0, 1), "example.org"),
+	}}
+	if _, err := db.Store(set); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := db.Load(RecordQuery{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.DMARC) != 2 {
+		t.Fatalf("loaded %d reports, want 2", len(loaded.DMARC))
+	}
+	for i, report := range loaded.DMARC {
+		want := set.DMARC[i]
+		if report.ReportMetadata.ReportID != want.ReportMetadata.ReportID ||
+			!report.ReportMetadata.DateRange.Begin.Equal(
+				want.ReportMetadata.DateRange.Begin,
+			) ||
+			len(report.Records) != len(want.Records) {
+			t.Errorf("report %d = %+v, want %+v", i, report, want)
+		}
+	}
+	if ip := loaded.DMARC[0].Records[1].Row.SourceIP; ip != "192.0.2.2" {
+		t.Errorf("second record from %s, want 192.0.2.2", ip)
+	}
+
+	infos, err := db.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].OrgName != "Google" ||
+		infos[0].Records != 2 || infos[1].Records != 0 {
+		t.Fatalf("List() = %+v", infos)
+	}
+
+	// Deleting a listed report removes it with its records
+	if err := db.DeleteReport(infos[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	results, err := db.QueryRecords(RecordQuery{SourceIP: "192.0.2.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 0 {
+		t.Errorf("deleted report still has %d records", len(results))
+	}
+	if err := db.DeleteReport(infos[0].ID); !errors.Is(err, ErrReportNotFound) {
+		t.Errorf("deleting twice: got %v, want ErrReportNotFound", err)
+	}
+}
+
+func TestDBDuplicates(t *testing.T) {
+	db := openTestDB(t)
+
+	set := ReportSet{DMARC: []model.DMARCReport{
+		testReport("Google", "a", day, "example.com", "192.0.2.1"),
+	}}
+	if duplicates, err := db.Store(set); err != nil || duplicates != 0 {
+		t.Fatalf("first Store() = %d, %v", duplicates, err)
+	}
+
+	// The reporter is compared case-insensitively
+	set.DMARC[0].ReportMetadata.OrgName = "GOOGLE"
+	if duplicates, err := db.Store(set); err != nil || duplicates != 1 {
+		t.Fatalf("second Store() = %d, %v, want 1 duplicate", duplicates, err)
+	}
+
+	// Reports without an ID are only duplicates if their content matches
+	set.DMARC = []model.DMARCReport{
+		testReport("Google", "", day, "example.com", "192.0.2.1"),
+		testReport("Google", "", day, "example.com", "192.0.2.2"),
+		testReport("Google", "", day, "example.com", "192.0.2.1"),
+	}
+	if duplicates, err := db.Store(set); err != nil || duplicates != 1 {
+		t.Fatalf("Store() without IDs = %d, %v, want 1 duplicate", duplicates, err)
+	}
+
+	id, err := db.SaveReport("report.xml", testXML("Yahoo", "b", "192.0.2.9"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.SaveReport("again.xml", testXML("Yahoo", "b", "192.0.2.9"))
+	if !errors.Is(err, ErrDuplicateReport) {
+		t.Errorf("saving twice: got %v, want ErrDuplicateReport", err)
+	}
+
+	infos, err := db.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 4 {
+		t.Fatalf("stored %d reports, want 4", len(infos))
+	}
+
+	// The ID SaveReport returns deletes the saved report, after which it
+	// can be saved again
+	if err := db.DeleteReport(id); err != nil {
+		t.Fatalf("DeleteReport(%q) failed: %v", id, err)
+	}
+	_, err = db.SaveReport("again.xml", testXML("Yahoo", "b", "192.0.2.9"))
+	if err != nil {
+		t.Errorf("saving after delete failed: %v", err)
+	}
+
+	// So can a report without an ID
+	if err := db.DeleteReport(infos[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	set.DMARC = set.DMARC[:1]
+	if duplicates, err := db.Store(set); err != nil || duplicates != 0 {
+		t.Errorf("Store() after delete = %d, %v", duplicates, err)
+	}
+}
+
+func TestDBQueryRecords(t *testing.T) {
+	db := openTestDB(t)
+
+	set := ReportSet{DMARC: []model.DMARCReport{
+		testReport("Google", "a", day, "example.com", "192.0.2.1", "2001:db8::1"),
+		testReport("Yahoo", "b", day.AddDate(0, 0, 1), "mail.example.com", "192.0.2.1"),
+		testReport("Google", "c", day.AddDate(0, 0, 2), "example.org", "198.51.100.7"),
+	}}
+	if _, err := db.Store(set); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		query   RecordQuery
+		reports []string
+	}{
+		{"all", RecordQuery{}, []string{"a", "a", "b", "c"}},
+		{"source IP", RecordQuery{SourceIP: "192.0.2.1"}, []string{"a", "b"}},
+		{
+			"IPv6 written differently",
+			RecordQuery{SourceIP: "2001:0DB8:0:0::1"},
+			[]string{"a"},
+		},
+		{"domain", RecordQuery{Domain: "EXAMPLE.com"}, []string{"a", "a"}},
+		{"reporter", RecordQuery{Reporter: "google"}, []string{"a", "a", "c"}},
+		{
+			"date range",
+			RecordQuery{Begin: day.AddDate(0, 0, 1), End: day.AddDate(0, 0, 2)},
+			[]string{"b"},
+		},
+		{
+			"reporter and source IP",
+			RecordQuery{Reporter: "Yahoo", SourceIP: "192.0.2.1"},
+			[]string{"b"},
+		},
+		{"no match", RecordQuery{Domain: "example.net"}, nil},
+	}
+
+	for _, tt := range tests {
+		results, err := db.QueryRecords(tt.query)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		var got []string
+		for _, result := range results {
+			got = append(got, result.Report.ReportID)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.reports) {
+			t.Errorf("%s: got reports %v, want %v", tt.name, got, tt.reports)
+		}
+	}
+
+	// Load keeps only the matching records of the matching reports
+	loaded, err := db.Load(RecordQuery{SourceIP: "192.0.2.1"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded.DMARC) != 2 || len(loaded.DMARC[0].Records) != 1 ||
+		len(loaded.DMARC[1].Records) != 1 {
+		t.Errorf("Load by source IP = %+v", loaded.DMARC)
+	}
+}
@@ -0,0 +1,126 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/huhndev/godmarc/mailbox"
+	"github.com/huhndev/godmarc/model"
+	"github.com/huhndev/godmarc/parser"
+)
+
+// loadMessageFile loads the report attachments of a single .eml file
+func loadMessageFile(state *loadState, path string, name string) {
+	data, err := readLimitedFile(path, state.limits().MaxDecompressedSize)
+	if err != nil {
+		state.addError("Error reading %s: %w", name, err)
+		return
+	}
+
+	loadMessage(state, data, name)
+}
+
+// loadMbox loads the report attachments of every message in an mbox file
+func loadMbox(state *loadState, path string, name string) {
+	f, err := os.Open(path)
+	if err != nil {
+		state.addError("Error reading %s: %w", name, err)
+		return
+	}
+	defer f.Close()
+
+	// The messages of an mbox share one budget, like the entries of a zip
+	// archive
+	messages, err := mailbox.SplitMbox(parser.LimitReader(
+		f,
+		state.limits().MaxDecompressedSize,
+		"MaxDecompressedSize",
+	))
+	if err != nil {
+		state.addError("Error reading %s: %w", name, err)
+		return
+	}
+
+	for i, data := range messages {
+		loadMessage(state, data, fmt.Sprintf("%s#%d", name, i+1))
+	}
+}
+
+// maildirJobs lists the messages of a Maildir to load. Each message is
+// loaded on its own.
+func maildirJobs(dir string, name string) []loadJob {
+	paths, err := mailbox.MaildirMessages(dir)
+	if err != nil {
+		return []loadJob{{
+			name: name,
+			load: func(state *loadState) {
+				state.addError("Error reading %s: %w", name, err)
+			},
+		}}
+	}
+
+	jobs := make([]loadJob, 0, len(paths))
+	for _, path := range paths {
+		messageName := filepath.Join(
+			name,
+			filepath.Base(filepath.Dir(path)),
+			filepath.Base(path),
+		)
+
+		var size int64
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+
+		jobs = append(jobs, loadJob{
+			name: messageName,
+			path: path,
+			size: size,
+			load: func(state *loadState) {
+				loadMessageFile(state, path, messageName)
+			},
+		})
+	}
+
+	return jobs
+}
+
+// loadMessage decodes a raw message and loads its xml, gzip and zip
+// attachments, tagging each report with the message's provenance, or the
+// failure report it carries. Messages without reports are skipped
+// silently, since report mailboxes routinely contain unrelated mail.
+func loadMessage(state *loadState, data []byte, name string) {
+	msg, err := mailbox.ParseMessage(bytes.NewReader(data))
+	if err != nil {
+		state.addError("Error reading message %s: %w", name, err)
+		return
+	}
+
+	// Failure reports (ruf) carry a feedback report instead of XML
+	if _, ok := msg.FeedbackReport(); ok {
+		report, err := parser.ParseForensicMessage(msg)
+		if err != nil {
+			state.addError("Error parsing failure report %s: %w", name, err)
+			return
+		}
+		state.addForensic(report)
+		return
+	}
+
+	provenance := model.Provenance{
+		Date:      msg.Date,
+		From:      msg.From,
+		MessageID: msg.MessageID,
+	}
+
+	for _, att := range msg.Attachments {
+		attName := name + "/" + att.Filename
+		if att.Filename == "" {
+			attName = name + "/" + att.ContentType
+		}
+
+		loadPayload(state, att.ReportFormat(), att.Data, attName, provenance)
+	}
+}
@@ -1,15 +1,20 @@
 package storage
 
 import (
+	"archive/zip"
+	"bytes"
 	"compress/gzip"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/huhndev/godmarc/mailbox"
 	"github.com/huhndev/godmarc/model"
 	"github.com/huhndev/godmarc/parser"
 )
@@ -17,16 +22,79 @@ import (
 // ReportLoader handles loading DMARC reports from the filesystem
 type ReportLoader struct {
 	ConfigDir string
+	// Limits bounds the resources a single report may use. Zero fields
+	// take the value from parser.DefaultLimits.
+	Limits parser.Limits
+	// Lenient keeps the readable parts of partially malformed aggregate
+	// reports, see parser.Parser.Lenient
+	Lenient bool
+	// Workers is the number of files loaded in parallel. Zero means one
+	// per CPU.
+	Workers int
+	// CacheFile is the file the reports of completely loaded files are
+	// cached in, so unchanged files are not parsed again. Empty disables
+	// the cache.
+	CacheFile string
+	// Progress, if set, is called after each file has been loaded. Calls
+	// are never concurrent.
+	Progress func(progress LoadProgress)
 }
 
-// ErrNoReports is returned when no reports are found
-var ErrNoReports = errors.New("no DMARC reports found")
+// LoadProgress describes how far loading the reports has come
+type LoadProgress struct {
+	// Done is the number of files, or stored reports of a database,
+	// loaded so far out of Total
+	Done  int
+	Total int
+	// Issues is the number of files so far that failed, were loaded
+	// partially or were skipped
+	Issues int
+}
+
+var (
+	// ErrNoReports is returned when no reports are found
+	ErrNoReports = errors.New("no DMARC reports found")
+	// ErrNoHomeDir is returned when the config directory cannot be located
+	ErrNoHomeDir = errors.New("could not determine home directory")
+	// ErrUnsupportedFile is the reason a file of unknown type is skipped
+	ErrUnsupportedFile = errors.New("unsupported file type")
+	// ErrSuspiciousName is the reason a file with an unsafe name is skipped
+	ErrSuspiciousName = errors.New("suspicious file name")
+	// ErrNoReportsInFile is the reason a message or mailbox without report
+	// attachments is skipped
+	ErrNoReportsInFile = errors.New("file contains no reports")
+)
+
+// LoadError is returned when files were found but none of them could be
+// loaded. It unwraps to the individual errors, so errors.Is and errors.As
+// match the parser's error types.
+type LoadError struct {
+	Errs []error
+}
+
+// Error implements error
+func (e *LoadError) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return "failed to parse any reports: " + strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors of the files that could not be loaded
+func (e *LoadError) Unwrap() []error {
+	return e.Errs
+}
+
+// maxSaveAttempts limits the number of suffixes tried to find a free file
+// name for a saved report
+const maxSaveAttempts = 1000
 
 // NewReportLoader creates a new ReportLoader instance with the default config directory
 func NewReportLoader() (*ReportLoader, error) {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
-		return nil, fmt.Errorf("could not determine home directory: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrNoHomeDir, err)
 	}
 
 	configDir := filepath.Join(homedir, ".godmarc")
@@ -44,23 +112,198 @@ func NewReportLoader() (*ReportLoader, error) {
 		return nil, fmt.Errorf("error accessing config directory %s: %w", configDir, err)
 	}
 
-	return &ReportLoader{
+	loader := &ReportLoader{
 		ConfigDir: configDir,
-	}, nil
+		Limits:    parser.DefaultLimits,
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		loader.CacheFile = filepath.Join(cacheDir, "godmarc", "reports.gob")
+	}
+
+	return loader, nil
 }
 
-// ParseResult represents the result of parsing a single file
+// LoadStatus is the outcome of loading a single file
+type LoadStatus string
+
+const (
+	// StatusLoaded means every report in the file was loaded
+	StatusLoaded LoadStatus = "loaded"
+	// StatusPartial means some reports in an archive or mailbox were
+	// loaded and others failed
+	StatusPartial LoadStatus = "partial"
+	// StatusFailed means the file could not be loaded at all
+	StatusFailed LoadStatus = "failed"
+	// StatusSkipped means the file was not read as a report
+	StatusSkipped LoadStatus = "skipped"
+)
+
+// ParseResult represents the result of loading a single file. Messages in
+// a Maildir are files of their own.
 type ParseResult struct {
 	Filename string
-	Report   model.DMARCReport
+	Size     int64
+	Status   LoadStatus
+	// Reports is the number of reports loaded from the file
+	Reports int
+	// Error explains a failed, partial or skipped file. For archives and
+	// mailboxes it joins the errors of all entries.
 	Error    error
+	Duration time.Duration
+}
+
+// Errors returns the individual errors of the result
+func (r ParseResult) Errors() []error {
+	if r.Error == nil {
+		return nil
+	}
+	if joined, ok := r.Error.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{r.Error}
+}
+
+// HasIssue reports whether the file was not loaded completely
+func (r ParseResult) HasIssue() bool {
+	return r.Status != StatusLoaded
+}
+
+// ReportSet holds all reports loaded from the config directory
+type ReportSet struct {
+	// DMARC holds the aggregate (rua) reports
+	DMARC []model.DMARCReport
+	// Forensic holds the failure (ruf) reports
+	Forensic []model.ForensicReport
+	// TLS holds the SMTP TLS (TLS-RPT) reports
+	TLS []model.TLSReport
+	// Results describes how each file was loaded, in directory order
+	Results []ParseResult
+}
+
+// loadState accumulates reports and errors while loading a directory
+type loadState struct {
+	parser      *parser.Parser
+	reports     []model.DMARCReport
+	forensic    []model.ForensicReport
+	tls         []model.TLSReport
+	parseErrors []error
+	results     []ParseResult
+	skipReason  error
+}
+
+// newLoadState creates the state for one load within the loader's limits
+func (l *ReportLoader) newLoadState() *loadState {
+	p := parser.NewParser(l.Limits)
+	p.Lenient = l.Lenient
+	return &loadState{parser: p}
+}
+
+// merge appends the reports, errors and results of other, which loaded
+// a later part of the directory
+func (s *loadState) merge(other *loadState) {
+	s.reports = append(s.reports, other.reports...)
+	s.forensic = append(s.forensic, other.forensic...)
+	s.tls = append(s.tls, other.tls...)
+	s.parseErrors = append(s.parseErrors, other.parseErrors...)
+	s.results = append(s.results, other.results...)
 }
 
-// LoadReports loads all DMARC reports from the config directory
+// limits returns the limits the load enforces
+func (s *loadState) limits() parser.Limits {
+	return s.parser.Limits()
+}
+
+// addReport records a successfully parsed report
+func (s *loadState) addReport(report model.DMARCReport) {
+	s.reports = append(s.reports, report)
+}
+
+// addForensic records a successfully parsed failure report
+func (s *loadState) addForensic(report model.ForensicReport) {
+	s.forensic = append(s.forensic, report)
+}
+
+// addTLS records a successfully parsed TLS report
+func (s *loadState) addTLS(report model.TLSReport) {
+	s.tls = append(s.tls, report)
+}
+
+// addError records a file or entry that could not be loaded
+func (s *loadState) addError(format string, args ...any) {
+	s.parseErrors = append(s.parseErrors, fmt.Errorf(format, args...))
+}
+
+// skip records why the file being loaded is not read as a report
+func (s *loadState) skip(reason error) {
+	s.skipReason = reason
+}
+
+// reportCount returns the number of reports loaded so far
+func (s *loadState) reportCount() int {
+	return len(s.reports) + len(s.forensic) + len(s.tls)
+}
+
+// loadTracked runs load for a single file and records its result, derived
+// from the reports and errors it added
+func (s *loadState) loadTracked(name string, size int64, load func()) {
+	start := time.Now()
+	reports := s.reportCount()
+	dmarc := len(s.reports)
+	errs := len(s.parseErrors)
+	s.skipReason = nil
+
+	load()
+
+	for i := dmarc; i < len(s.reports); i++ {
+		s.reports[i].Source = name
+	}
+
+	result := ParseResult{
+		Filename: name,
+		Size:     size,
+		Reports:  s.reportCount() - reports,
+		Duration: time.Since(start),
+	}
+
+	fileErrs := s.parseErrors[errs:]
+	switch {
+	case len(fileErrs) > 0 && result.Reports > 0:
+		result.Status = StatusPartial
+		result.Error = errors.Join(fileErrs...)
+	case len(fileErrs) > 0:
+		result.Status = StatusFailed
+		result.Error = errors.Join(fileErrs...)
+	case result.Reports > 0:
+		result.Status = StatusLoaded
+	default:
+		result.Status = StatusSkipped
+		result.Error = s.skipReason
+		if result.Error == nil {
+			result.Error = ErrNoReportsInFile
+		}
+	}
+
+	s.results = append(s.results, result)
+}
+
+// LoadReports loads all DMARC aggregate reports from the config directory
 func (l *ReportLoader) LoadReports() ([]model.DMARCReport, error) {
+	set, err := l.LoadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(set.DMARC) == 0 {
+		return nil, ErrNoReports
+	}
+	return set.DMARC, nil
+}
+
+// LoadAll loads all aggregate, failure and TLS reports from the config
+// directory
+func (l *ReportLoader) LoadAll() (ReportSet, error) {
 	files, err := os.ReadDir(l.ConfigDir)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return ReportSet{}, fmt.Errorf(
 			"failed to read directory %s: %w",
 			l.ConfigDir,
 			err,
@@ -68,125 +311,639 @@ func (l *ReportLoader) LoadReports() ([]model.DMARCReport, error) {
 	}
 
 	if len(files) == 0 {
-		return nil, fmt.Errorf("%w in %s", ErrNoReports, l.ConfigDir)
+		return ReportSet{}, fmt.Errorf("%w in %s", ErrNoReports, l.ConfigDir)
 	}
 
-	var reports []model.DMARCReport
-	var parseErrors []string
+	state := l.runJobs(l.loadJobs(files))
 
-	successCount := 0
-	failureCount := 0
+	set := ReportSet{
+		DMARC:    state.reports,
+		Forensic: state.forensic,
+		TLS:      state.tls,
+		Results:  state.results,
+	}
 
-	for _, file := range files {
-		if file.IsDir() {
-			continue
+	if state.reportCount() == 0 {
+		if len(state.parseErrors) > 0 {
+			return set, &LoadError{Errs: state.parseErrors}
 		}
+		return set, ErrNoReports
+	}
+
+	return set, nil
+}
 
+// loadJob is a single file, or Maildir message, to load
+type loadJob struct {
+	name string
+	// path is the file to load, or empty if the job reads no file
+	path string
+	size int64
+	load func(state *loadState)
+}
+
+// loadJobs lists the files of the config directory to load, in directory
+// order
+func (l *ReportLoader) loadJobs(files []os.DirEntry) []loadJob {
+	var jobs []loadJob
+
+	for _, file := range files {
 		filename := file.Name()
 
-		// Check for suspicious filenames (path traversal attempts)
-		cleanPath := filepath.Clean(filename)
-		if cleanPath != filename || filepath.IsAbs(cleanPath) ||
-			strings.Contains(cleanPath, "..") ||
-			strings.Contains(cleanPath, "/") ||
-			strings.Contains(cleanPath, "\\") {
-			parseErrors = append(
-				parseErrors,
-				fmt.Sprintf(
-					"Security warning: skipping suspicious filename: %s",
-					filename,
-				),
-			)
-			failureCount++
+		// Hidden files hold state and in-progress writes, not reports
+		if strings.HasPrefix(filename, ".") {
 			continue
 		}
 
 		filePath := filepath.Join(l.ConfigDir, filename)
 
-		// Handle gzipped files
-		if strings.HasSuffix(strings.ToLower(filename), ".gz") {
-			xmlPath, err := decompressGzip(filePath)
-			if err != nil {
-				parseErrors = append(
-					parseErrors,
-					fmt.Sprintf("Error decompressing %s: %v", filename, err),
-				)
-				failureCount++
-				continue
+		// Maildir directories hold one message per file
+		if file.IsDir() {
+			if mailbox.IsMaildir(filePath) {
+				jobs = append(jobs, maildirJobs(filePath, filename)...)
+			} else {
+				jobs = append(jobs, loadJob{
+					name: filename,
+					load: func(state *loadState) {
+						state.skip(ErrUnsupportedFile)
+					},
+				})
 			}
-			defer os.Remove(xmlPath)
-			filePath = xmlPath
-		} else if !strings.HasSuffix(strings.ToLower(filename), ".xml") {
 			continue
 		}
 
-		report, err := parser.ParseDMARCReport(filePath)
-		if err != nil {
-			parseErrors = append(
-				parseErrors,
-				fmt.Sprintf("Error parsing %s: %v", filename, err),
-			)
-			failureCount++
-			continue
+		var size int64
+		if info, err := file.Info(); err == nil {
+			size = info.Size()
 		}
 
-		reports = append(reports, report)
-		successCount++
+		jobs = append(jobs, loadJob{
+			name: filename,
+			path: filePath,
+			size: size,
+			load: func(state *loadState) {
+				loadEntry(state, filePath, filename)
+			},
+		})
 	}
 
-	if len(reports) == 0 {
-		if len(parseErrors) > 0 {
-			return nil, fmt.Errorf(
-				"failed to parse any reports: %s",
-				strings.Join(parseErrors, "; "),
-			)
+	return jobs
+}
+
+// runJobs loads the files in parallel on up to l.Workers goroutines. Each
+// file is loaded into a state of its own, and the states are merged in
+// the order of jobs, so the result does not depend on scheduling.
+// Unchanged files are taken from the cache, which is then replaced by the
+// files of this load, dropping files that were removed.
+func (l *ReportLoader) runJobs(jobs []loadJob) *loadState {
+	var cache *reportCache
+	if l.CacheFile != "" {
+		cache = l.readCache()
+	}
+
+	workers := l.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = min(workers, len(jobs))
+
+	states := make([]*loadState, len(jobs))
+	entries := make([]*cacheEntry, len(jobs))
+	next := make(chan int)
+	done := make(chan int)
+
+	for range workers {
+		go func() {
+			for i := range next {
+				states[i], entries[i] = l.runJob(jobs[i], cache)
+				done <- i
+			}
+		}()
+	}
+
+	go func() {
+		for i := range jobs {
+			next <- i
+		}
+		close(next)
+	}()
+
+	progress := LoadProgress{Total: len(jobs)}
+	for range jobs {
+		i := <-done
+		progress.Done++
+		for _, result := range states[i].results {
+			if result.HasIssue() {
+				progress.Issues++
+			}
+		}
+		if l.Progress != nil {
+			l.Progress(progress)
 		}
-		return nil, ErrNoReports
 	}
 
-	if len(parseErrors) > 0 {
-		fmt.Printf(
-			"Warning: %d of %d files failed to parse\n",
-			failureCount,
-			successCount+failureCount,
-		)
-		for _, errMsg := range parseErrors {
-			fmt.Println(errMsg)
+	merged := l.newLoadState()
+	for _, state := range states {
+		merged.merge(state)
+	}
+
+	if cache != nil {
+		updated := l.newReportCache()
+		for i, entry := range entries {
+			if entry != nil {
+				updated.Entries[jobs[i].path] = *entry
+			}
+		}
+		l.writeCache(updated)
+	}
+
+	return merged
+}
+
+// runJob loads a single file, or takes it from cache if it is unchanged.
+// It also returns the cache entry for the file, or nil if the file is not
+// to be cached.
+func (l *ReportLoader) runJob(
+	job loadJob,
+	cache *reportCache,
+) (*loadState, *cacheEntry) {
+	start := time.Now()
+
+	var key fileKey
+	cacheable := false
+	if cache != nil && job.path != "" {
+		var err error
+		if key, err = cache.fileKey(job.path); err == nil {
+			if entry, ok := cache.lookup(job.path, key); ok {
+				state := entry.state()
+				state.results[0].Duration = time.Since(start)
+				return state, &entry
+			}
+			cacheable = true
+		}
+	}
+
+	state := l.newLoadState()
+	state.loadTracked(job.name, job.size, func() {
+		job.load(state)
+	})
+
+	if !cacheable {
+		return state, nil
+	}
+	entry, ok := state.entry(key)
+	if !ok {
+		return state, nil
+	}
+	return state, &entry
+}
+
+// loadEntry loads a single file of the config directory by its type
+func loadEntry(state *loadState, path string, name string) {
+	// Check for suspicious filenames (path traversal attempts)
+	cleanPath := filepath.Clean(name)
+	if cleanPath != name || filepath.IsAbs(cleanPath) ||
+		strings.Contains(cleanPath, "..") ||
+		strings.Contains(cleanPath, "/") ||
+		strings.Contains(cleanPath, "\\") {
+		state.skip(ErrSuspiciousName)
+		return
+	}
+
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".eml":
+		loadMessageFile(state, path, name)
+	case ".mbox":
+		loadMbox(state, path, name)
+	default:
+		loadFile(state, mailbox.FormatFromFilename(name), path, name)
+	}
+}
+
+// loadFile loads a report file of the given format (see
+// mailbox.FormatFromFilename). Other files are skipped.
+func loadFile(state *loadState, format string, path string, name string) {
+	switch format {
+	case ".zip":
+		loadZipFile(state, path, name)
+	case ".gz", ".json.gz":
+		loadGzipFile(state, path, name, decompressedFormat(format))
+	case ".xml":
+		report, err := state.parser.ParseDMARCReport(path)
+		if err != nil {
+			state.addError("Error parsing %s: %w", name, err)
+			return
 		}
+		state.addReport(report)
+	case ".json":
+		data, err := readLimitedFile(path, state.limits().MaxDecompressedSize)
+		if err != nil {
+			state.addError("Error reading %s: %w", name, err)
+			return
+		}
+		loadPayload(state, format, data, name, model.Provenance{})
+	default:
+		state.skip(ErrUnsupportedFile)
+	}
+}
+
+// loadZipFile loads every XML entry of a zip archive on disk
+func loadZipFile(state *loadState, zipPath string, name string) {
+	f, err := os.Open(zipPath)
+	if err != nil {
+		state.addError("Error extracting %s: %w", name, err)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		state.addError("Error extracting %s: %w", name, err)
+		return
 	}
 
-	return reports, nil
+	loadZip(state, f, info.Size(), name, model.Provenance{})
 }
 
-// decompressGzip decompresses a gzipped file and returns the path to a temporary XML file
-func decompressGzip(gzPath string) (string, error) {
+// loadZip loads every XML entry of a zip archive as a report of its own.
+// Entries are parsed straight from the archive without extracting them.
+func loadZip(
+	state *loadState,
+	r io.ReaderAt,
+	size int64,
+	name string,
+	provenance model.Provenance,
+) {
+	limits := state.limits()
+	if size > limits.MaxCompressedSize {
+		state.addError(
+			"Error extracting %s: %w",
+			name,
+			&parser.LimitError{
+				Limit: "MaxCompressedSize",
+				Max:   limits.MaxCompressedSize,
+			},
+		)
+		return
+	}
+
+	err := walkZip(r, size, limits, func(entry string, rc io.Reader) error {
+		entryName := name + "/" + entry
+		report, err := state.parser.ParseDMARCReportReader(rc, entryName)
+		if err != nil {
+			state.addError("Error parsing %s: %w", entryName, err)
+			return nil
+		}
+
+		report.Provenance = provenance
+		state.addReport(report)
+		return nil
+	})
+	if err != nil {
+		state.addError("Error extracting %s: %w", name, err)
+	}
+}
+
+// loadGzipFile loads a gzip-compressed report from disk. The format of
+// the decompressed report is ".xml" or ".json".
+func loadGzipFile(state *loadState, gzPath string, name string, format string) {
 	f, err := os.Open(gzPath)
 	if err != nil {
-		return "", fmt.Errorf("could not open gzip file: %w", err)
+		state.addError("Error decompressing %s: %w", name, err)
+		return
 	}
 	defer f.Close()
 
-	gr, err := gzip.NewReader(f)
+	loadGzip(state, f, name, format, model.Provenance{})
+}
+
+// loadGzip loads a gzip-compressed report whose decompressed format is
+// ".xml" or ".json". XML is parsed while it is decompressed.
+func loadGzip(
+	state *loadState,
+	r io.Reader,
+	name string,
+	format string,
+	provenance model.Provenance,
+) {
+	limits := state.limits()
+	r = parser.LimitReader(r, limits.MaxCompressedSize, "MaxCompressedSize")
+
+	gr, err := gzip.NewReader(r)
 	if err != nil {
-		return "", fmt.Errorf("could not create gzip reader: %w", err)
+		state.addError(
+			"Error decompressing %s: could not create gzip reader: %w",
+			name,
+			err,
+		)
+		return
 	}
 	defer gr.Close()
 
-	tmpFile, err := os.CreateTemp("", "godmarc-*.xml")
+	// The parser enforces the decompressed size limit itself
+	if format == ".xml" {
+		report, err := state.parser.ParseDMARCReportReader(gr, name)
+		if err != nil {
+			state.addError("Error parsing %s: %w", name, err)
+			return
+		}
+		report.Provenance = provenance
+		state.addReport(report)
+		return
+	}
+
+	// Fail on decompression bombs instead of truncating them
+	data, err := io.ReadAll(parser.LimitReader(
+		gr,
+		limits.MaxDecompressedSize,
+		"MaxDecompressedSize",
+	))
+	if err != nil {
+		state.addError(
+			"Error decompressing %s: could not decompress file: %w",
+			name,
+			err,
+		)
+		return
+	}
+
+	loadPayload(state, format, data, name, provenance)
+}
+
+// loadPayload loads the reports in an in-memory payload of the given
+// format (see mailbox.FormatFromFilename). Other formats are ignored.
+func loadPayload(
+	state *loadState,
+	format string,
+	data []byte,
+	name string,
+	provenance model.Provenance,
+) {
+	switch format {
+	case ".zip":
+		loadZip(
+			state,
+			bytes.NewReader(data),
+			int64(len(data)),
+			name,
+			provenance,
+		)
+	case ".gz", ".json.gz":
+		loadGzip(
+			state,
+			bytes.NewReader(data),
+			name,
+			decompressedFormat(format),
+			provenance,
+		)
+	case ".json":
+		report, err := state.parser.ParseTLSReportData(data, name)
+		if err != nil {
+			state.addError("Error parsing %s: %w", name, err)
+			return
+		}
+		report.Provenance = provenance
+		state.addTLS(report)
+	case ".xml":
+		report, err := state.parser.ParseDMARCReportData(data, name)
+		if err != nil {
+			state.addError("Error parsing %s: %w", name, err)
+			return
+		}
+		report.Provenance = provenance
+		state.addReport(report)
+	}
+}
+
+// loadData loads the reports in an in-memory file, identified by its name
+// or, failing that, its content
+func loadData(state *loadState, name string, data []byte) {
+	if strings.ToLower(filepath.Ext(name)) == ".eml" {
+		loadMessage(state, data, name)
+		return
+	}
+
+	format := mailbox.FormatFromFilename(name)
+	if format == "" {
+		format = DetectFormat(data)
+	}
+	loadPayload(state, format, data, name, model.Provenance{})
+}
+
+// decompressedFormat returns the format of a gzip payload's content
+func decompressedFormat(format string) string {
+	if format == ".json.gz" {
+		return ".json"
+	}
+	return ".xml"
+}
+
+// DetectFormat identifies an in-memory report payload by its magic bytes
+// and returns ".gz", ".zip" or, for anything else, ".xml"
+func DetectFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return ".gz"
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return ".zip"
+	default:
+		return ".xml"
+	}
+}
+
+// ParseReportData parses every report in an in-memory xml, gzip or zip
+// payload within the loader's limits. It returns the reports that parsed
+// together with one error per report or archive that did not.
+func (l *ReportLoader) ParseReportData(
+	data []byte,
+	name string,
+) ([]model.DMARCReport, []error) {
+	state := l.newLoadState()
+	loadPayload(state, DetectFormat(data), data, name, model.Provenance{})
+
+	return state.reports, state.parseErrors
+}
+
+// readLimitedFile reads a file into memory, failing with a
+// *parser.LimitError if it is larger than max
+func readLimitedFile(path string, max int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return io.ReadAll(parser.LimitReader(f, max, "MaxDecompressedSize"))
+}
+
+// SaveReport stores a report file (xml, gz, zip or eml) in the config
+// directory and returns the name of the file, which is its ID. The name is
+// reduced to a safe base name and made unique, so existing files are never
+// overwritten, and the file only appears once it is completely written.
+func (l *ReportLoader) SaveReport(name string, data []byte) (string, error) {
+	tmp, err := os.CreateTemp(l.ConfigDir, ".incoming-*")
 	if err != nil {
 		return "", fmt.Errorf("could not create temp file: %w", err)
 	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("could not write report: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("could not write report: %w", err)
+	}
+
+	base := sanitizeFilename(name)
+	ext := filepath.Ext(base)
+	stem := strings.TrimSuffix(base, ext)
+	if inner := strings.ToLower(filepath.Ext(stem)); inner == ".xml" ||
+		inner == ".json" {
+		// Keep double extensions like .xml.gz together
+		ext = filepath.Ext(stem) + ext
+		stem = strings.TrimSuffix(stem, filepath.Ext(stem))
+	}
+
+	for i := 0; i < maxSaveAttempts; i++ {
+		candidate := base
+		if i > 0 {
+			candidate = fmt.Sprintf("%s-%d%s", stem, i, ext)
+		}
+
+		target := filepath.Join(l.ConfigDir, candidate)
+
+		// Linking fails if the target exists, unlike renaming
+		err := os.Link(tmp.Name(), target)
+		if err == nil {
+			return candidate, nil
+		}
+		if !os.IsExist(err) {
+			return "", fmt.Errorf("could not store report %s: %w", candidate, err)
+		}
+	}
+
+	return "", fmt.Errorf("could not find a free file name for %s", base)
+}
+
+// sanitizeFilename reduces a file name from an untrusted source to a safe
+// base name for the config directory
+func sanitizeFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '/' || r == ':' {
+			return '_'
+		}
+		return r
+	}, name)
+
+	// Hidden files are ignored by the loader
+	name = strings.TrimLeft(name, ".")
+	if name == "" {
+		name = "report"
+	}
+
+	return name
+}
+
+// walkZip calls fn with a decompressing reader for each XML entry of a zip
+// archive, stopping at the first error fn returns. It guards against
+// decompression bombs, and it rejects archives whose entry names try to
+// escape the extraction directory before any entry is read.
+func walkZip(
+	r io.ReaderAt,
+	size int64,
+	limits parser.Limits,
+	fn func(name string, rc io.Reader) error,
+) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("could not open zip file: %w", err)
+	}
+
+	if len(zr.File) > limits.MaxArchiveEntries {
+		return fmt.Errorf(
+			"archive has %d entries: %w",
+			len(zr.File),
+			&parser.LimitError{
+				Limit: "MaxArchiveEntries",
+				Max:   int64(limits.MaxArchiveEntries),
+			},
+		)
+	}
+
+	var reports []*zip.File
+	for _, f := range zr.File {
+		if !isSafeEntryName(f.Name) {
+			return fmt.Errorf("suspicious entry name: %q", f.Name)
+		}
+		if !f.FileInfo().IsDir() &&
+			strings.HasSuffix(strings.ToLower(f.Name), ".xml") {
+			reports = append(reports, f)
+		}
+	}
 
-	// Limit decompressed size to 50MB to prevent decompression bombs
-	limited := io.LimitReader(gr, 50*1024*1024)
-	if _, err := io.Copy(tmpFile, limited); err != nil {
-		tmpFile.Close()
-		os.Remove(tmpFile.Name())
-		return "", fmt.Errorf("could not decompress file: %w", err)
+	// All entries share one decompression budget. What is actually
+	// decompressed counts, not the size the archive claims.
+	entries := &entryReader{}
+	budget := parser.LimitReader(
+		entries,
+		limits.MaxDecompressedSize,
+		"MaxDecompressedSize",
+	)
+
+	for i, f := range reports {
+		rc, err := f.Open()
+		if err != nil {
+			return fmt.Errorf("could not open entry %s: %w", f.Name, err)
+		}
+
+		entries.r = rc
+		err = fn(f.Name, budget)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+
+		// The entry that used up the budget failed on its own; only the
+		// entries that are never read need reporting here
+		if entries.n > limits.MaxDecompressedSize && i < len(reports)-1 {
+			return fmt.Errorf(
+				"skipped %d entries: %w",
+				len(reports)-1-i,
+				&parser.LimitError{
+					Limit: "MaxDecompressedSize",
+					Max:   limits.MaxDecompressedSize,
+				},
+			)
+		}
 	}
 
-	tmpFile.Close()
-	return tmpFile.Name(), nil
+	return nil
+}
+
+// entryReader reads from the current entry of an archive and counts the
+// bytes read from all entries
+type entryReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader
+func (e *entryReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	e.n += int64(n)
+	return n, err
+}
+
+// isSafeEntryName reports whether an archive entry name stays inside the
+// directory it would be extracted to
+func isSafeEntryName(name string) bool {
+	if name == "" || strings.Contains(name, "\\") {
+		return false
+	}
+	return filepath.IsLocal(name)
 }
 
 // SortReportsByDate sorts reports by date (newest first)
@@ -197,3 +954,17 @@ func SortReportsByDate(reports []model.DMARCReport) {
 		)
 	})
 }
+
+// SortTLSReportsByDate sorts TLS reports by date (newest first)
+func SortTLSReportsByDate(reports []model.TLSReport) {
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].DateRange.Start.After(reports[j].DateRange.Start)
+	})
+}
+
+// SortForensicReportsByDate sorts failure reports by date (newest first)
+func SortForensicReportsByDate(reports []model.ForensicReport) {
+	sort.Slice(reports, func(i, j int) bool {
+		return reports[i].Date().After(reports[j].Date())
+	})
+}
@@ -1,19 +1,23 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/help"
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/huhndev/godmarc/formatter"
 	"github.com/huhndev/godmarc/model"
+	"github.com/huhndev/godmarc/provider"
 	"github.com/huhndev/godmarc/storage"
 )
 
@@ -21,12 +25,21 @@ const (
 	tabReports    = 0
 	tabAggregated = 1
 	tabFailed     = 2
+	tabForensic   = 3
+	tabTLS        = 4
+	tabIssues     = 5
+	tabSenders    = 6
 )
 
 // Model represents the state of the application
 type Model struct {
 	reports        []model.DMARCReport
+	orgDomains     [][]string
+	forensic       []model.ForensicReport
+	tlsReports     []model.TLSReport
+	loadResults    []storage.ParseResult
 	aggregated     model.AggregatedReport
+	senders        []model.Sender
 	list           list.Model
 	viewport       viewport.Model
 	help           help.Model
@@ -36,7 +49,9 @@ type Model struct {
 	activeTab      int
 	width          int
 	height         int
-	loader         *storage.ReportLoader
+	backend        storage.Backend
+	query          storage.RecordQuery
+	resolvePTR     bool
 	errorMsg       string
 	showError      bool
 	errorTimeout   time.Time
@@ -45,6 +60,13 @@ type Model struct {
 	searchFilter   string
 	filteredItems  []list.Item
 	allItems       []list.Item
+	showDuplicates bool
+	duplicates     int
+	spinner        spinner.Model
+	loading        bool
+	loaded         bool
+	loadProgress   storage.LoadProgress
+	loadErr        error
 }
 
 // showErrorMessage displays an error message for a specified duration
@@ -60,23 +82,35 @@ func (m *Model) clearErrorMessage() {
 	m.showError = false
 }
 
-// NewModel creates a new application model
-func NewModel() (Model, error) {
-	loader, err := storage.NewReportLoader()
-	if err != nil {
-		return Model{}, fmt.Errorf(
-			"failed to initialize report loader: %w",
-			err,
-		)
-	}
+// Config holds the startup options of the application
+type Config struct {
+	// Lenient keeps the readable parts of partially malformed reports
+	Lenient bool
+	// Workers is the number of files loaded in parallel, zero means one
+	// per CPU
+	Workers int
+	// Database is the database file to read reports from instead of the
+	// config directory
+	Database string
+	// ResolvePTR looks up the reverse DNS names of source IP addresses to
+	// classify them by provider
+	ResolvePTR bool
+	// Query selects the aggregate report records to load, so a database
+	// only reads the reports of interest
+	Query storage.RecordQuery
+}
+
+// resolveTimeout bounds the reverse DNS lookups of a load
+const resolveTimeout = 30 * time.Second
 
-	reports, err := loader.LoadReports()
+// NewModel creates a new application model. The reports are loaded in the
+// background once the program starts.
+func NewModel(config Config) (Model, error) {
+	backend, err := newBackend(config)
 	if err != nil {
-		return Model{}, fmt.Errorf("failed to load reports: %w", err)
+		return Model{}, err
 	}
 
-	storage.SortReportsByDate(reports)
-
 	keys := DefaultKeyMap()
 
 	h := help.New()
@@ -91,30 +125,69 @@ func NewModel() (Model, error) {
 	ti.Placeholder = "Search reports..."
 	ti.CharLimit = 100
 
-	items := CreateReportListItems(reports)
+	s := spinner.New()
+	s.Spinner = spinner.Dot
+	s.Style = lipgloss.NewStyle().Foreground(ColorPink)
 
 	m := Model{
-		reports:     reports,
-		aggregated:  model.AggregateReports(reports),
-		list:        l,
-		viewport:    vp,
-		help:        h,
-		keys:        keys,
-		loader:      loader,
-		searchInput: ti,
-		allItems:    items,
+		list:           l,
+		viewport:       vp,
+		help:           h,
+		keys:           keys,
+		backend:        backend,
+		query:          config.Query,
+		resolvePTR:     config.ResolvePTR,
+		searchInput:    ti,
+		spinner:        s,
+		loading:        true,
+		showDuplicates: true,
 	}
 
-	m.list.SetItems(items)
-
 	return m, nil
 }
 
-// Init initializes the model
+// Init starts loading the reports
 func (m Model) Init() tea.Cmd {
+	return tea.Batch(m.spinner.Tick, loadReports(m.backend, m.query, m.resolvePTR))
+}
+
+// newBackend opens the storage the configuration selects
+func newBackend(config Config) (storage.Backend, error) {
+	if config.Database != "" {
+		db, err := storage.OpenDB(config.Database)
+		if err != nil {
+			return nil, err
+		}
+		db.Lenient = config.Lenient
+		return db, nil
+	}
+
+	loader, err := storage.NewReportLoader()
+	if err != nil {
+		return nil, fmt.Errorf(
+			"failed to initialize report loader: %w",
+			err,
+		)
+	}
+	loader.Lenient = config.Lenient
+	loader.Workers = config.Workers
+	return loader, nil
+}
+
+// Close releases the storage of the model
+func (m Model) Close() error {
+	if db, ok := m.backend.(*storage.DB); ok {
+		return db.Close()
+	}
 	return nil
 }
 
+// Err returns the error that stopped the initial load, if any. The
+// program quits when the initial load fails.
+func (m Model) Err() error {
+	return m.loadErr
+}
+
 // Update handles messages and updates the model
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var cmd tea.Cmd
@@ -142,6 +215,20 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.showErrorMessage(msg.error.Error(), 5*time.Second)
 		return m, nil
 
+	case loadProgressMsg:
+		m.loadProgress = msg.progress
+		return m, waitForLoad(msg.updates)
+
+	case loadDoneMsg:
+		return m.finishLoad(msg)
+
+	case spinner.TickMsg:
+		if !m.loading {
+			return m, nil
+		}
+		m.spinner, cmd = m.spinner.Update(msg)
+		return m, cmd
+
 	case tea.WindowSizeMsg:
 		m, cmd = m.handleWindowResize(msg)
 		cmds = append(cmds, cmd)
@@ -158,6 +245,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		}
 
+		// Nothing to navigate until the reports are loaded
+		if m.loading {
+			return m, nil
+		}
+
 		// View-specific key handlers
 		if m.showReport {
 			m, cmd = m.handleReportViewKeys(msg)
@@ -176,6 +268,22 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.activeTab = tabFailed
 				m.refreshTabContent()
 				return m, nil
+			case key.Matches(msg, m.keys.Tab4):
+				m.activeTab = tabForensic
+				m.refreshTabContent()
+				return m, nil
+			case key.Matches(msg, m.keys.Tab5):
+				m.activeTab = tabTLS
+				m.refreshTabContent()
+				return m, nil
+			case key.Matches(msg, m.keys.Tab6):
+				m.activeTab = tabIssues
+				m.refreshTabContent()
+				return m, nil
+			case key.Matches(msg, m.keys.Tab7):
+				m.activeTab = tabSenders
+				m.refreshTabContent()
+				return m, nil
 			case key.Matches(msg, m.keys.Search):
 				m.searching = true
 				m.searchInput.Focus()
@@ -194,7 +302,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					m, cmd = m.handleListViewKeys(msg)
 				}
 			} else {
-				// Viewport-based tabs (aggregated, failed)
+				// Viewport-based tabs (aggregated, failed, forensic, TLS)
 				m.viewport, cmd = m.viewport.Update(msg)
 			}
 		}
@@ -251,11 +359,22 @@ func (m *Model) applySearchFilter() {
 	}
 
 	filter := strings.ToLower(m.searchFilter)
+
+	// A domain also finds the reports of its organizational domain and
+	// all its subdomains
+	var orgDomain string
+	domain := strings.TrimSpace(filter)
+	if model.HasOrganizationalDomain(domain) {
+		orgDomain = model.OrganizationalDomain(domain)
+	}
+
 	filtered := make([]list.Item, 0)
 	for _, item := range m.allItems {
 		if ri, ok := item.(ReportItem); ok {
 			searchable := strings.ToLower(ri.FilterValue())
-			if strings.Contains(searchable, filter) {
+			if strings.Contains(searchable, filter) ||
+				orgDomain != "" &&
+					slices.Contains(m.orgDomains[ri.Index], orgDomain) {
 				filtered = append(filtered, item)
 			}
 		}
@@ -263,6 +382,25 @@ func (m *Model) applySearchFilter() {
 	m.list.SetItems(filtered)
 }
 
+// reportOrgDomains returns the organizational domains of the records of
+// each report, for the search
+func reportOrgDomains(reports []model.DMARCReport) [][]string {
+	orgDomains := make([][]string, len(reports))
+	for i, report := range reports {
+		for _, record := range report.Records {
+			domain := record.Identifiers.HeaderFrom
+			if domain == "" {
+				domain = report.PolicyPublished.Domain
+			}
+			org := model.OrganizationalDomain(domain)
+			if !slices.Contains(orgDomains[i], org) {
+				orgDomains[i] = append(orgDomains[i], org)
+			}
+		}
+	}
+	return orgDomains
+}
+
 // handleWindowResize handles window resize events
 func (m Model) handleWindowResize(msg tea.WindowSizeMsg) (Model, tea.Cmd) {
 	m.width = msg.Width
@@ -308,6 +446,22 @@ func (m *Model) refreshTabContent() {
 		m.viewport = viewport.New(m.width, contentHeight)
 		m.viewport.SetContent(formatter.FormatFailedRecords(m.aggregated, m.width))
 		m.viewport.GotoTop()
+	case m.activeTab == tabForensic:
+		m.viewport = viewport.New(m.width, contentHeight)
+		m.viewport.SetContent(formatter.FormatForensicReports(m.forensic, m.reports, m.width))
+		m.viewport.GotoTop()
+	case m.activeTab == tabTLS:
+		m.viewport = viewport.New(m.width, contentHeight)
+		m.viewport.SetContent(formatter.FormatTLSReports(m.tlsReports, m.width))
+		m.viewport.GotoTop()
+	case m.activeTab == tabIssues:
+		m.viewport = viewport.New(m.width, contentHeight)
+		m.viewport.SetContent(formatter.FormatLoadIssues(m.loadResults, m.width))
+		m.viewport.GotoTop()
+	case m.activeTab == tabSenders:
+		m.viewport = viewport.New(m.width, contentHeight)
+		m.viewport.SetContent(formatter.FormatSenders(m.senders, m.width))
+		m.viewport.GotoTop()
 	}
 }
 
@@ -316,6 +470,11 @@ func (m Model) handleListViewKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 	switch {
 	case key.Matches(msg, m.keys.Reload):
 		return m.reloadReports()
+	case key.Matches(msg, m.keys.Duplicates):
+		m.showDuplicates = !m.showDuplicates
+		m.allItems = CreateReportListItems(m.reports, m.showDuplicates)
+		m.applySearchFilter()
+		return m, nil
 	case key.Matches(msg, m.keys.Enter):
 		return m.showSelectedReport()
 	}
@@ -337,20 +496,109 @@ func (m Model) handleReportViewKeys(msg tea.KeyMsg) (Model, tea.Cmd) {
 	return m, cmd
 }
 
-// reloadReports reloads reports from disk
+// reloadReports reloads reports from disk in the background
 func (m Model) reloadReports() (Model, tea.Cmd) {
-	reports, err := m.loader.LoadReports()
-	if err != nil {
-		return m, func() tea.Msg {
-			return errorMsg{err}
+	m.loading = true
+	m.loadProgress = storage.LoadProgress{}
+	return m, tea.Batch(m.spinner.Tick, loadReports(m.backend, m.query, m.resolvePTR))
+}
+
+// loadReports returns a command that loads the reports matching query from
+// backend. It emits a loadProgressMsg as files are loaded and finally a
+// loadDoneMsg. With resolvePTR, the reverse DNS names of the sources are
+// looked up before it is done.
+func loadReports(
+	backend storage.Backend,
+	query storage.RecordQuery,
+	resolvePTR bool,
+) tea.Cmd {
+	return func() tea.Msg {
+		updates := make(chan tea.Msg, 1)
+
+		// While the UI is busy, a pending update is replaced by the newer
+		// one, so only the latest counts are shown
+		progress := func(progress storage.LoadProgress) {
+			msg := loadProgressMsg{progress, updates}
+			for {
+				select {
+				case updates <- msg:
+					return
+				default:
+				}
+
+				select {
+				case <-updates:
+				default:
+				}
+			}
+		}
+
+		go func() {
+			set, err := backend.Load(query, progress)
+			if err == nil && resolvePTR {
+				resolveSources(set.DMARC)
+			}
+			updates <- loadDoneMsg{set, err}
+		}()
+
+		return <-updates
+	}
+}
+
+// resolveSources looks up the reverse DNS names of the source IP addresses
+// of reports, for provider.Classify
+func resolveSources(reports []model.DMARCReport) {
+	var ips []string
+	for _, report := range reports {
+		for _, record := range report.Records {
+			ips = append(ips, record.Row.SourceIP)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	provider.Resolve(ctx, ips)
+}
+
+// waitForLoad returns a command that waits for the next message of a
+// running load
+func waitForLoad(updates chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-updates
+	}
+}
+
+// finishLoad shows the reports of a completed load. If the initial load
+// fails, the program quits, otherwise the previous reports are kept.
+func (m Model) finishLoad(msg loadDoneMsg) (Model, tea.Cmd) {
+	m.loading = false
+
+	if msg.err != nil {
+		if !m.loaded {
+			m.loadErr = fmt.Errorf("failed to load reports: %w", msg.err)
+			return m, tea.Quit
 		}
+		m.showErrorMessage(msg.err.Error(), 5*time.Second)
+		return m, nil
 	}
 
+	set := msg.set
+	reports := set.DMARC
+
+	// The copy loaded first is the original
+	m.duplicates = model.MarkDuplicates(reports)
 	storage.SortReportsByDate(reports)
+	storage.SortForensicReportsByDate(set.Forensic)
+	storage.SortTLSReportsByDate(set.TLS)
 
 	m.reports = reports
+	m.orgDomains = reportOrgDomains(reports)
+	m.forensic = set.Forensic
+	m.tlsReports = set.TLS
+	m.loadResults = set.Results
 	m.aggregated = model.AggregateReports(reports)
-	items := CreateReportListItems(reports)
+	m.senders = model.Senders(reports)
+	items := CreateReportListItems(reports, m.showDuplicates)
 	m.allItems = items
 	m.list.SetItems(items)
 
@@ -359,10 +607,13 @@ func (m Model) reloadReports() (Model, tea.Cmd) {
 		m.applySearchFilter()
 	}
 
-	m.showErrorMessage(
-		fmt.Sprintf("Loaded %d reports", len(reports)),
-		3*time.Second,
-	)
+	if m.loaded {
+		m.showErrorMessage(
+			fmt.Sprintf("Loaded %d reports", len(reports)),
+			3*time.Second,
+		)
+	}
+	m.loaded = true
 
 	m.refreshTabContent()
 
@@ -371,7 +622,13 @@ func (m Model) reloadReports() (Model, tea.Cmd) {
 
 // showSelectedReport switches to the report detail view
 func (m Model) showSelectedReport() (Model, tea.Cmd) {
-	m.selectedReport = m.list.Index()
+	item, ok := m.list.SelectedItem().(ReportItem)
+	if !ok {
+		return m, nil
+	}
+
+	// The list may be filtered, so its index is not the report's
+	m.selectedReport = item.Index
 	if m.selectedReport >= 0 && m.selectedReport < len(m.reports) {
 		m.showReport = true
 		m.refreshTabContent()
@@ -393,7 +650,9 @@ func (m Model) View() string {
 	)
 
 	var content string
-	if m.showReport {
+	if m.loading && !m.loaded {
+		content = m.renderLoading()
+	} else if m.showReport {
 		content = m.viewport.View()
 	} else if m.activeTab == tabReports {
 		content = m.list.View()
@@ -419,6 +678,41 @@ func (m Model) View() string {
 	return AppStyle.Render(lipgloss.JoinVertical(lipgloss.Left, parts...))
 }
 
+// renderLoading renders the progress of the initial load
+func (m Model) renderLoading() string {
+	const barWidth = 40
+
+	p := m.loadProgress
+	filled := 0
+	if p.Total > 0 {
+		filled = barWidth * p.Done / p.Total
+	}
+	bar := PassStyle.Render(strings.Repeat("█", filled)) +
+		lipgloss.NewStyle().Foreground(ColorDimGray).Render(
+			strings.Repeat("░", barWidth-filled),
+		)
+
+	lines := []string{
+		m.spinner.View() + " Loading reports from " + m.backend.Name(),
+		"",
+		bar,
+		fmt.Sprintf("%d/%d files", p.Done, p.Total),
+	}
+	if p.Issues > 0 {
+		lines = append(lines, WarnStyle.Render(
+			fmt.Sprintf("%d files with issues", p.Issues),
+		))
+	}
+
+	return lipgloss.Place(
+		m.width,
+		m.height-8,
+		lipgloss.Center,
+		lipgloss.Center,
+		lipgloss.JoinVertical(lipgloss.Left, lines...),
+	)
+}
+
 // renderTabBar renders the tab navigation bar
 func (m Model) renderTabBar() string {
 	if m.showReport {
@@ -432,6 +726,10 @@ func (m Model) renderTabBar() string {
 		{"Reports", m.activeTab == tabReports},
 		{"Aggregated", m.activeTab == tabAggregated},
 		{"Failed", m.activeTab == tabFailed},
+		{"Forensic", m.activeTab == tabForensic},
+		{"TLS", m.activeTab == tabTLS},
+		{"Load Issues", m.activeTab == tabIssues},
+		{"Senders", m.activeTab == tabSenders},
 	}
 
 	rendered := make([]string, len(tabs))
@@ -461,16 +759,49 @@ func (m Model) renderStatusBar() string {
 	var left string
 	var right string
 
-	if m.showReport {
+	if m.loading {
+		left = " " + m.spinner.View() + fmt.Sprintf(
+			" loading %d/%d files",
+			m.loadProgress.Done,
+			m.loadProgress.Total,
+		)
+		if m.loadProgress.Issues > 0 {
+			left += fmt.Sprintf(" | %d issues", m.loadProgress.Issues)
+		}
+	} else if m.showReport {
 		reportName := m.reports[m.selectedReport].ReportMetadata.ReportID
 		left = fmt.Sprintf(" Report: %s", reportName)
 		right = fmt.Sprintf("scroll: %.0f%% ", m.viewport.ScrollPercent()*100)
 	} else {
-		totalReports := len(m.reports)
+		// Duplicates are counted separately below
+		totalReports := m.aggregated.TotalReports
 		totalRecords := m.aggregated.TotalRecords
 		failedCount := len(m.aggregated.FailedRecords)
 
 		left = fmt.Sprintf(" %d reports | %d records", totalReports, totalRecords)
+		if m.aggregated.TotalMessages > 0 {
+			left += fmt.Sprintf(
+				" | %d msgs: %.1f%% pass, %s",
+				m.aggregated.TotalMessages,
+				m.aggregated.ComplianceRate()*100,
+				m.dispositionSummary(),
+			)
+		}
+		if len(m.forensic) > 0 {
+			left += fmt.Sprintf(" | %d failure reports", len(m.forensic))
+		}
+		if len(m.tlsReports) > 0 {
+			left += fmt.Sprintf(" | %d TLS reports", len(m.tlsReports))
+		}
+		if issues := m.loadIssueCount(); issues > 0 {
+			left += fmt.Sprintf(" | %d load issues", issues)
+		}
+		if m.duplicates > 0 {
+			left += fmt.Sprintf(" | %d duplicates", m.duplicates)
+			if !m.showDuplicates {
+				left += " hidden"
+			}
+		}
 
 		if failedCount > 0 {
 			right = FailStyle.Render(fmt.Sprintf("%d failed ", failedCount))
@@ -501,9 +832,44 @@ func (m Model) renderStatusBar() string {
 	return StatusBarStyle.Render(left + strings.Repeat(" ", gap) + right)
 }
 
+// dispositionSummary returns the share of messages that were rejected and
+// quarantined
+func (m Model) dispositionSummary() string {
+	return fmt.Sprintf(
+		"%.1f%% quarantine, %.1f%% reject",
+		m.aggregated.Share(m.aggregated.Dispositions["quarantine"])*100,
+		m.aggregated.Share(m.aggregated.Dispositions["reject"])*100,
+	)
+}
+
+// loadIssueCount returns the number of files that were not loaded
+// completely
+func (m Model) loadIssueCount() int {
+	count := 0
+	for _, result := range m.loadResults {
+		if result.HasIssue() {
+			count++
+		}
+	}
+	return count
+}
+
 // Custom message types
 type checkErrorTimeoutMsg struct{}
 
 type errorMsg struct {
 	error error
 }
+
+// loadProgressMsg reports the progress of a running load. updates
+// delivers the next message of the load.
+type loadProgressMsg struct {
+	progress storage.LoadProgress
+	updates  chan tea.Msg
+}
+
+// loadDoneMsg carries the result of a completed load
+type loadDoneMsg struct {
+	set storage.ReportSet
+	err error
+}
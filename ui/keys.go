@@ -6,16 +6,21 @@ import (
 
 // KeyMap defines the keybindings for the application
 type KeyMap struct {
-	Up     key.Binding
-	Down   key.Binding
-	Enter  key.Binding
-	Back   key.Binding
-	Quit   key.Binding
-	Reload key.Binding
-	Search key.Binding
-	Tab1   key.Binding
-	Tab2   key.Binding
-	Tab3   key.Binding
+	Up         key.Binding
+	Down       key.Binding
+	Enter      key.Binding
+	Back       key.Binding
+	Quit       key.Binding
+	Reload     key.Binding
+	Search     key.Binding
+	Duplicates key.Binding
+	Tab1       key.Binding
+	Tab2       key.Binding
+	Tab3       key.Binding
+	Tab4       key.Binding
+	Tab5       key.Binding
+	Tab6       key.Binding
+	Tab7       key.Binding
 }
 
 // DefaultKeyMap returns the default key bindings
@@ -49,6 +54,10 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("/"),
 			key.WithHelp("/", "search"),
 		),
+		Duplicates: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "show/hide duplicates"),
+		),
 		Tab1: key.NewBinding(
 			key.WithKeys("1"),
 			key.WithHelp("1", "reports"),
@@ -61,6 +70,22 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("3"),
 			key.WithHelp("3", "failed"),
 		),
+		Tab4: key.NewBinding(
+			key.WithKeys("4"),
+			key.WithHelp("4", "forensic"),
+		),
+		Tab5: key.NewBinding(
+			key.WithKeys("5"),
+			key.WithHelp("5", "tls"),
+		),
+		Tab6: key.NewBinding(
+			key.WithKeys("6"),
+			key.WithHelp("6", "load issues"),
+		),
+		Tab7: key.NewBinding(
+			key.WithKeys("7"),
+			key.WithHelp("7", "senders"),
+		),
 	}
 }
 
@@ -72,5 +97,5 @@ func RenderHelp(showReport bool, activeTab int, searching bool, keys KeyMap) str
 	if showReport {
 		return "↑/k up · ↓/j down · esc back · q quit"
 	}
-	return "↑/k up · ↓/j down · enter select · 1/2/3 tabs · / search · r reload · q quit"
+	return "↑/k up · ↓/j down · enter select · 1-7 tabs · / search · d duplicates · r reload · q quit"
 }
@@ -12,6 +12,8 @@ import (
 // ReportItem is a list item for the list model
 type ReportItem struct {
 	Report model.DMARCReport
+	// Index is the position of the report in the list it was created from
+	Index int
 }
 
 // Title returns the title for the item
@@ -47,13 +49,23 @@ func (r ReportItem) Description() string {
 		spfStatus = FailStyle.Render(fmt.Sprintf("SPF %d/%d", spfPass, total))
 	}
 
-	return fmt.Sprintf(
+	description := fmt.Sprintf(
 		"%s | %d records | %s %s",
 		r.Report.ReportMetadata.OrgName,
 		total,
 		dkimStatus,
 		spfStatus,
 	)
+
+	// Leniently parsed reports are missing data
+	if r.Report.Degraded {
+		description += " | " + WarnStyle.Render("⚠ incomplete")
+	}
+	if r.Report.Duplicate {
+		description += " | " + WarnStyle.Render("duplicate")
+	}
+
+	return description
 }
 
 // FilterValue returns the value used for filtering
@@ -66,11 +78,18 @@ func (r ReportItem) FilterValue() string {
 	}, " ")
 }
 
-// CreateReportListItems creates list items from DMARC reports
-func CreateReportListItems(reports []model.DMARCReport) []list.Item {
-	items := make([]list.Item, len(reports))
+// CreateReportListItems creates list items from DMARC reports, leaving out
+// duplicates unless showDuplicates is set
+func CreateReportListItems(
+	reports []model.DMARCReport,
+	showDuplicates bool,
+) []list.Item {
+	items := make([]list.Item, 0, len(reports))
 	for i, report := range reports {
-		items[i] = ReportItem{Report: report}
+		if report.Duplicate && !showDuplicates {
+			continue
+		}
+		items = append(items, ReportItem{Report: report, Index: i})
 	}
 	return items
 }
@@ -96,7 +115,7 @@ func SetupReportList(
 
 	listDelegate.SetSpacing(1)
 
-	items := CreateReportListItems(reports)
+	items := CreateReportListItems(reports, true)
 
 	l := list.New(items, listDelegate, width, height-6)
 	l.Title = "DMARC Reports"
@@ -127,7 +127,7 @@ func ColorizeResult(result string) string {
 // ColorizeDisposition returns a styled string for dispositions
 func ColorizeDisposition(disp string) string {
 	switch disp {
-	case "none":
+	case "none", "pass":
 		return PassStyle.Render(disp)
 	case "reject":
 		return FailStyle.Render(disp)